@@ -0,0 +1,257 @@
+// Package kafkatest provides an in-memory stand-in for Kafka, so that the service can be run
+// end to end in tests. Its consumers and producers implement source.Source and sink.Sink.
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+var errClosed = errors.New("closed")
+
+// Broker keeps every topic as an append-only log of messages. Each consumer reads its topic
+// from the start, one message at a time, and moves on once the handler returns.
+type Broker struct {
+	mu           sync.Mutex
+	topics       map[string][]kafka.FTMessage
+	unreachable  error
+	lagTolerance int
+	// changed is closed, then replaced, whenever a message is published or the broker comes back.
+	changed chan struct{}
+}
+
+type BrokerOption func(*Broker)
+
+// WithLagTolerance makes the consumers report lag when more than n messages are waiting for them.
+func WithLagTolerance(n int) BrokerOption {
+	return func(b *Broker) {
+		b.lagTolerance = n
+	}
+}
+
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		topics:  map[string][]kafka.FTMessage{},
+		changed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish appends m to topic. It fails while the broker is unreachable.
+func (b *Broker) Publish(topic string, m kafka.FTMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unreachable != nil {
+		return b.unreachable
+	}
+	b.topics[topic] = append(b.topics[topic], copyMessage(m))
+	b.notify()
+	return nil
+}
+
+// Messages returns the messages published to topic so far.
+func (b *Broker) Messages(topic string) []kafka.FTMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	messages := make([]kafka.FTMessage, 0, len(b.topics[topic]))
+	for _, m := range b.topics[topic] {
+		messages = append(messages, copyMessage(m))
+	}
+	return messages
+}
+
+// WaitForMessages returns the messages of topic as soon as there are at least n of them,
+// or an error when ctx is done first.
+func (b *Broker) WaitForMessages(ctx context.Context, topic string, n int) ([]kafka.FTMessage, error) {
+	for {
+		b.mu.Lock()
+		count := len(b.topics[topic])
+		changed := b.changed
+		b.mu.Unlock()
+		if count >= n {
+			return b.Messages(topic), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%d of %d messages published to %s: %w", count, n, topic, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// SetUnreachable makes the connectivity checks and the producers fail with err, and stops the delivery
+// to the consumers. A nil err brings the broker back.
+func (b *Broker) SetUnreachable(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unreachable = err
+	b.notify()
+}
+
+func (b *Broker) check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unreachable
+}
+
+// notify wakes up the consumers and waiters. The lock must be held.
+func (b *Broker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Producer returns a producer writing to topic.
+func (b *Broker) Producer(topic string) *Producer {
+	return &Producer{broker: b, topic: topic}
+}
+
+// Consumer returns a consumer reading topic from its first message.
+func (b *Broker) Consumer(topic string) *Consumer {
+	return &Consumer{broker: b, topic: topic, stop: make(chan struct{})}
+}
+
+type Producer struct {
+	broker *Broker
+	topic  string
+	mu     sync.Mutex
+	closed bool
+}
+
+func (p *Producer) SendMessage(m kafka.FTMessage) error {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return fmt.Errorf("producer of %s: %w", p.topic, errClosed)
+	}
+	return p.broker.Publish(p.topic, m)
+}
+
+func (p *Producer) ConnectivityCheck() error {
+	return p.broker.check()
+}
+
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+type Consumer struct {
+	broker   *Broker
+	topic    string
+	mu       sync.Mutex
+	offset   int
+	stop     chan struct{}
+	stopOnce sync.Once
+	// handling is held while a message is handled, so that Close can wait for it.
+	handling sync.Mutex
+}
+
+// Start hands the messages of the topic to handler until the consumer is closed.
+func (c *Consumer) Start(handler func(kafka.FTMessage)) {
+	for {
+		m, ok := c.next()
+		if !ok || !c.handle(handler, m) {
+			return
+		}
+	}
+}
+
+// handle moves the offset past m once handler returns, and reports false when the consumer was closed first.
+func (c *Consumer) handle(handler func(kafka.FTMessage), m kafka.FTMessage) bool {
+	c.handling.Lock()
+	defer c.handling.Unlock()
+	select {
+	case <-c.stop:
+		return false
+	default:
+	}
+
+	handler(m)
+	c.mu.Lock()
+	c.offset++
+	c.mu.Unlock()
+	return true
+}
+
+// next waits for the message at the offset of the consumer, and reports false once the consumer is closed.
+func (c *Consumer) next() (kafka.FTMessage, bool) {
+	for {
+		c.broker.mu.Lock()
+		c.mu.Lock()
+		offset := c.offset
+		c.mu.Unlock()
+		messages := c.broker.topics[c.topic]
+		changed := c.broker.changed
+		available := c.broker.unreachable == nil && offset < len(messages)
+		var m kafka.FTMessage
+		if available {
+			m = copyMessage(messages[offset])
+		}
+		c.broker.mu.Unlock()
+
+		select {
+		case <-c.stop:
+			return kafka.FTMessage{}, false
+		default:
+		}
+		if available {
+			return m, true
+		}
+
+		select {
+		case <-c.stop:
+			return kafka.FTMessage{}, false
+		case <-changed:
+		}
+	}
+}
+
+func (c *Consumer) ConnectivityCheck() error {
+	return c.broker.check()
+}
+
+// MonitorCheck fails when more messages are waiting than the lag tolerance of the broker.
+func (c *Consumer) MonitorCheck() error {
+	if err := c.broker.check(); err != nil {
+		return err
+	}
+	c.broker.mu.Lock()
+	published := len(c.broker.topics[c.topic])
+	tolerance := c.broker.lagTolerance
+	c.broker.mu.Unlock()
+	c.mu.Lock()
+	lag := published - c.offset
+	c.mu.Unlock()
+
+	if lag > tolerance {
+		return fmt.Errorf("consumer of %s is lagging by %d messages", c.topic, lag)
+	}
+	return nil
+}
+
+// Close stops the delivery of messages, and waits for the one being handled.
+func (c *Consumer) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.handling.Lock()
+	defer c.handling.Unlock()
+	return nil
+}
+
+func copyMessage(m kafka.FTMessage) kafka.FTMessage {
+	headers := make(map[string]string, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	return kafka.FTMessage{Headers: headers, Body: m.Body}
+}
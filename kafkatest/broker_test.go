@@ -0,0 +1,149 @@
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_DeliversInOrder(t *testing.T) {
+	b := NewBroker()
+	require.NoError(t, b.Producer("in").SendMessage(kafka.FTMessage{Body: "first"}))
+
+	consumer := b.Consumer("in")
+	received := make(chan string, 2)
+	go consumer.Start(func(m kafka.FTMessage) {
+		received <- m.Body
+	})
+	defer consumer.Close()
+	require.NoError(t, b.Publish("in", kafka.FTMessage{Body: "second"}))
+
+	assert.Equal(t, "first", <-received)
+	assert.Equal(t, "second", <-received)
+}
+
+func TestBroker_Unreachable(t *testing.T) {
+	b := NewBroker()
+	producer := b.Producer("out")
+	consumer := b.Consumer("in")
+	b.SetUnreachable(errors.New("broker is down"))
+
+	assert.EqualError(t, producer.SendMessage(kafka.FTMessage{Body: "lost"}), "broker is down")
+	assert.EqualError(t, producer.ConnectivityCheck(), "broker is down")
+	assert.EqualError(t, consumer.ConnectivityCheck(), "broker is down")
+	assert.Empty(t, b.Messages("out"))
+
+	b.SetUnreachable(nil)
+	assert.NoError(t, producer.ConnectivityCheck())
+	assert.NoError(t, producer.SendMessage(kafka.FTMessage{Body: "sent"}))
+	assert.Len(t, b.Messages("out"), 1)
+}
+
+func TestConsumer_MonitorCheck(t *testing.T) {
+	b := NewBroker(WithLagTolerance(1))
+	consumer := b.Consumer("in")
+	require.NoError(t, b.Publish("in", kafka.FTMessage{Body: "first"}))
+	assert.NoError(t, consumer.MonitorCheck())
+
+	require.NoError(t, b.Publish("in", kafka.FTMessage{Body: "second"}))
+	assert.EqualError(t, consumer.MonitorCheck(), "consumer of in is lagging by 2 messages")
+
+	done := make(chan struct{})
+	go consumer.Start(func(m kafka.FTMessage) {
+		if m.Body == "second" {
+			close(done)
+		}
+	})
+	<-done
+	require.NoError(t, consumer.Close())
+	assert.Eventually(t, func() bool { return consumer.MonitorCheck() == nil }, time.Second, time.Millisecond)
+}
+
+func TestConsumer_CloseStopsStart(t *testing.T) {
+	consumer := NewBroker().Consumer("in")
+	stopped := make(chan struct{})
+	go func() {
+		consumer.Start(func(kafka.FTMessage) {})
+		close(stopped)
+	}()
+
+	require.NoError(t, consumer.Close())
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Start should return once the consumer is closed")
+	}
+}
+
+func TestConsumer_CloseWaitsForTheHandler(t *testing.T) {
+	b := NewBroker()
+	require.NoError(t, b.Publish("in", kafka.FTMessage{Body: "first"}))
+	require.NoError(t, b.Publish("in", kafka.FTMessage{Body: "second"}))
+
+	consumer := b.Consumer("in")
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	var handled []string
+	go consumer.Start(func(m kafka.FTMessage) {
+		close(handling)
+		<-release
+		handled = append(handled, m.Body)
+	})
+	<-handling
+
+	closed := make(chan struct{})
+	go func() {
+		_ = consumer.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close should wait for the message being handled")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-closed
+	assert.Equal(t, []string{"first"}, handled, "No message should be handled once the consumer is closed")
+	assert.EqualError(t, consumer.MonitorCheck(), "consumer of in is lagging by 1 messages")
+}
+
+func TestProducer_Closed(t *testing.T) {
+	producer := NewBroker().Producer("out")
+	require.NoError(t, producer.Close())
+	assert.EqualError(t, producer.SendMessage(kafka.FTMessage{}), "producer of out: closed")
+}
+
+func TestBroker_WaitForMessages(t *testing.T) {
+	b := NewBroker()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = b.Publish("out", kafka.FTMessage{Body: "late"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	messages, err := b.WaitForMessages(ctx, "out", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "late", messages[0].Body)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.WaitForMessages(ctx, "out", 2)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBroker_CopiesMessages(t *testing.T) {
+	b := NewBroker()
+	headers := map[string]string{"X-Request-Id": "tid_1"}
+	require.NoError(t, b.Publish("out", kafka.FTMessage{Headers: headers, Body: "body"}))
+	headers["X-Request-Id"] = "tid_2"
+
+	b.Messages("out")[0].Headers["X-Request-Id"] = "tid_3"
+	assert.Equal(t, "tid_1", b.Messages("out")[0].Headers["X-Request-Id"])
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/Financial-Times/kafka-client-go/v4"
 	cli "github.com/jawher/mow.cli"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/service-status-go/httphandlers"
+	"github.com/Financial-Times/upp-next-video-mapper/metrics"
+	"github.com/Financial-Times/upp-next-video-mapper/sink"
+	"github.com/Financial-Times/upp-next-video-mapper/source"
+	"github.com/Financial-Times/upp-next-video-mapper/tracing"
 	"github.com/Financial-Times/upp-next-video-mapper/video"
 	"github.com/gorilla/mux"
 )
@@ -64,6 +71,32 @@ func main() {
 		EnvVar: "Q_WRITE_TOPIC",
 	})
 
+	feedbackTopic := app.String(cli.StringOpt{
+		Name:   "feedback-topic",
+		Desc:   "The topic to publish the mapping warnings to, for editorial feedback. Nothing is published when empty.",
+		EnvVar: "Q_FEEDBACK_TOPIC",
+	})
+
+	deadLetterTopic := app.String(cli.StringOpt{
+		Name:   "dead-letter-topic",
+		Desc:   "The topic to send the messages whose handling panicked to. They are skipped when empty.",
+		EnvVar: "Q_DEAD_LETTER_TOPIC",
+	})
+
+	panicThreshold := app.Int(cli.IntOpt{
+		Name:   "panic-threshold",
+		Value:  0,
+		Desc:   "Number of panics within the panic window above which the health check turns red",
+		EnvVar: "PANIC_THRESHOLD",
+	})
+
+	panicWindow := app.Int(cli.IntOpt{
+		Name:   "panic-window",
+		Value:  900,
+		Desc:   "Seconds during which a recovered panic counts towards the panic threshold",
+		EnvVar: "PANIC_WINDOW",
+	})
+
 	appPort := app.Int(cli.IntOpt{
 		Name:   "port",
 		Value:  8080,
@@ -91,101 +124,415 @@ func main() {
 		EnvVar: "KAFKA_CLUSTER_ARN",
 	})
 
+	sourceType := app.String(cli.StringOpt{
+		Name:   "source",
+		Value:  source.TypeKafka,
+		Desc:   "Where to read the native messages from (kafka, file, http)",
+		EnvVar: "SOURCE_TYPE",
+	})
+
+	sourceDirectory := app.String(cli.StringOpt{
+		Name:   "source-directory",
+		Value:  "incoming",
+		Desc:   "Directory the file source watches for message envelopes",
+		EnvVar: "SOURCE_DIRECTORY",
+	})
+
+	sourcePollInterval := app.Int(cli.IntOpt{
+		Name:   "source-poll-interval",
+		Value:  5,
+		Desc:   "How often, in seconds, the file source looks for new files",
+		EnvVar: "SOURCE_POLL_INTERVAL",
+	})
+
+	sourcePendingTolerance := app.Int(cli.IntOpt{
+		Name:   "source-pending-tolerance",
+		Value:  120,
+		Desc:   "How many files may wait in the directory of the file source before it is reported as lagging, 0 for no limit",
+		EnvVar: "SOURCE_PENDING_TOLERANCE",
+	})
+
+	ingestAPIKey := app.String(cli.StringOpt{
+		Name:   "ingest-api-key",
+		Desc:   "API key the http source expects in the X-Api-Key header",
+		EnvVar: "INGEST_API_KEY",
+	})
+
+	sinkType := app.String(cli.StringOpt{
+		Name:   "sink",
+		Value:  sink.TypeKafka,
+		Desc:   "Where to write the mapped messages (kafka, stdout, file, webhook)",
+		EnvVar: "SINK_TYPE",
+	})
+
+	sinkFilePath := app.String(cli.StringOpt{
+		Name:   "sink-file-path",
+		Value:  "next-video-mapper.ndjson",
+		Desc:   "NDJSON file the file sink writes to",
+		EnvVar: "SINK_FILE_PATH",
+	})
+
+	sinkFileMaxBytes := app.Int(cli.IntOpt{
+		Name:   "sink-file-max-bytes",
+		Value:  100 * 1024 * 1024,
+		Desc:   "Size in bytes after which the file sink rotates its file",
+		EnvVar: "SINK_FILE_MAX_BYTES",
+	})
+
+	sinkFileMaxBackups := app.Int(cli.IntOpt{
+		Name:   "sink-file-max-backups",
+		Value:  5,
+		Desc:   "Number of rotated files the file sink keeps",
+		EnvVar: "SINK_FILE_MAX_BACKUPS",
+	})
+
+	sinkWebhookURL := app.String(cli.StringOpt{
+		Name:   "sink-webhook-url",
+		Desc:   "URL the webhook sink POSTs the mapped messages to",
+		EnvVar: "SINK_WEBHOOK_URL",
+	})
+
+	sinkWebhookAuthorization := app.String(cli.StringOpt{
+		Name:   "sink-webhook-authorization",
+		Desc:   "Authorization header sent by the webhook sink",
+		EnvVar: "SINK_WEBHOOK_AUTHORIZATION",
+	})
+
+	policyFile := app.String(cli.StringOpt{
+		Name:   "publication-policy-file",
+		Value:  "",
+		Desc:   "JSON file with the publication rules videos are checked against before being sent (empty disables the check)",
+		EnvVar: "PUBLICATION_POLICY_FILE",
+	})
+
+	paragraphGap := app.Int(cli.IntOpt{
+		Name:   "transcript-paragraph-gap",
+		Value:  2000,
+		Desc:   "Silence in milliseconds between two caption cues that starts a new paragraph in the transcripts generated from captions",
+		EnvVar: "TRANSCRIPT_PARAGRAPH_GAP",
+	})
+
+	durationTolerance := app.Int(cli.IntOpt{
+		Name:   "duration-tolerance",
+		Value:  1000,
+		Desc:   "Difference in milliseconds between the duration of a rendition and the one of the primary rendition above which a warning is raised",
+		EnvVar: "DURATION_TOLERANCE",
+	})
+
+	dateValidation := app.String(cli.StringOpt{
+		Name:   "date-validation",
+		Value:  video.DateValidationWarn,
+		Desc:   "What to do with dates that are unparseable, in the future or out of order (warn, fail)",
+		EnvVar: "DATE_VALIDATION",
+	})
+
+	maxFutureSkew := app.Int(cli.IntOpt{
+		Name:   "date-max-future-skew",
+		Value:  300,
+		Desc:   "Seconds a date may be in the future before it is reported",
+		EnvVar: "DATE_MAX_FUTURE_SKEW",
+	})
+
+	videoContentType := app.String(cli.StringOpt{
+		Name:   "content-type-video",
+		Value:  video.DefaultTypeMapping.Video,
+		Desc:   "UPP type of the mapped videos",
+		EnvVar: "CONTENT_TYPE_VIDEO",
+	})
+
+	audioContentType := app.String(cli.StringOpt{
+		Name:   "content-type-audio",
+		Value:  video.DefaultTypeMapping.Audio,
+		Desc:   "UPP type of the mapped audio-only content, such as podcasts",
+		EnvVar: "CONTENT_TYPE_AUDIO",
+	})
+
+	tracingExporter := app.String(cli.StringOpt{
+		Name:   "tracing-exporter",
+		Value:  tracing.ExporterNone,
+		Desc:   "Where to export the traces to (none, otlp, file)",
+		EnvVar: "TRACING_EXPORTER",
+	})
+
+	tracingOTLPEndpoint := app.String(cli.StringOpt{
+		Name:   "tracing-otlp-endpoint",
+		Desc:   "OTLP/HTTP endpoint URL of the trace collector, the OTEL_EXPORTER_OTLP_* variables are used when empty",
+		EnvVar: "TRACING_OTLP_ENDPOINT",
+	})
+
+	tracingFilePath := app.String(cli.StringOpt{
+		Name:   "tracing-file-path",
+		Value:  "traces.json",
+		Desc:   "File the file exporter writes the traces to",
+		EnvVar: "TRACING_FILE_PATH",
+	})
+
 	log := logger.NewUPPLogger(serviceName, *logLevel)
 
 	log.Infof("[Startup] %s is starting", serviceName)
 
 	app.Action = func() {
+		s := settings{
+			appName:       *appName,
+			appSystemCode: *appSystemCode,
+			tracing: tracing.Config{
+				Exporter:     *tracingExporter,
+				OTLPEndpoint: *tracingOTLPEndpoint,
+				FilePath:     *tracingFilePath,
+				ServiceName:  *appSystemCode,
+			},
+			sink: sink.Config{
+				Type: *sinkType,
+				Kafka: kafka.ProducerConfig{
+					ClusterArn:              clusterArn,
+					BrokersConnectionString: *kafkaAddress,
+					Topic:                   *writeTopic,
+				},
+				FilePath:             *sinkFilePath,
+				FileMaxBytes:         int64(*sinkFileMaxBytes),
+				FileMaxBackups:       *sinkFileMaxBackups,
+				WebhookURL:           *sinkWebhookURL,
+				WebhookAuthorization: *sinkWebhookAuthorization,
+				WebhookTimeout:       10 * time.Second,
+			},
+			source: source.Config{
+				Type: *sourceType,
+				Kafka: kafka.ConsumerConfig{
+					ClusterArn:              clusterArn,
+					BrokersConnectionString: *kafkaAddress,
+					ConsumerGroup:           *group,
+				},
+				KafkaTopic:        *readTopic,
+				KafkaLagTolerance: int64(*consumerLagTolerance),
+				Directory:         *sourceDirectory,
+				PollInterval:      time.Duration(*sourcePollInterval) * time.Second,
+				PendingTolerance:  *sourcePendingTolerance,
+				IngestAPIKey:      *ingestAPIKey,
+			},
+			feedbackTopic:     *feedbackTopic,
+			deadLetterTopic:   *deadLetterTopic,
+			panicThreshold:    *panicThreshold,
+			panicWindow:       time.Duration(*panicWindow) * time.Second,
+			policyFile:        *policyFile,
+			paragraphGap:      time.Duration(*paragraphGap) * time.Millisecond,
+			durationTolerance: time.Duration(*durationTolerance) * time.Millisecond,
+			dateValidation: video.DateValidation{
+				Mode:          *dateValidation,
+				MaxFutureSkew: time.Duration(*maxFutureSkew) * time.Second,
+			},
+			typeMapping: video.TypeMapping{Video: *videoContentType, Audio: *audioContentType},
+		}
 
-		if *kafkaAddress == "" {
-			log.Fatal("No queue kafkaAddress provided. Quitting...")
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(*appPort))
+		if err != nil {
+			log.WithError(err).Fatal("Couldn't set up HTTP listener")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if err := run(ctx, s, connectors{newSource: source.New, newSink: sink.New}, listener, log); err != nil {
+			log.WithError(err).Fatal("Failed to run the service")
 		}
+	}
 
-		producerConfig := kafka.ProducerConfig{
-			ClusterArn:              clusterArn,
-			BrokersConnectionString: *kafkaAddress,
-			Topic:                   *writeTopic,
+	err := app.Run(os.Args)
+	if err != nil {
+		println(err)
+	}
+}
+
+// settings are the options of the service, read from the command line or the environment.
+type settings struct {
+	appName           string
+	appSystemCode     string
+	tracing           tracing.Config
+	sink              sink.Config
+	source            source.Config
+	feedbackTopic     string
+	deadLetterTopic   string
+	panicThreshold    int
+	panicWindow       time.Duration
+	policyFile        string
+	paragraphGap      time.Duration
+	durationTolerance time.Duration
+	dateValidation    video.DateValidation
+	typeMapping       video.TypeMapping
+}
+
+// connectors create the source and the sinks of the service, so that tests can replace Kafka.
+type connectors struct {
+	newSource func(source.Config, *logger.UPPLogger) (source.Source, error)
+	newSink   func(sink.Config) (sink.Sink, error)
+}
+
+// run wires the service up, serves its endpoints on listener and maps the messages of the source
+// until ctx is done.
+func run(ctx context.Context, s settings, c connectors, listener net.Listener, log *logger.UPPLogger) error {
+	shutdownTracing, err := tracing.Setup(ctx, s.tracing)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.WithError(err).Error("Couldn't flush traces")
+		}
+	}()
+
+	producer, err := c.newSink(s.sink)
+	if err != nil {
+		return fmt.Errorf("failed to create %s sink: %w", s.sink.Type, err)
+	}
+	defer func(producer sink.Sink) {
+		err := producer.Close()
+		if err != nil {
+			log.WithError(err).Error("Producer could not stop")
+		}
+	}(producer)
+
+	m := metrics.NewPrometheus()
+	panics := video.NewPanicTracker(s.panicThreshold, s.panicWindow)
+	handlerOpts := []video.HandlerOption{
+		video.WithHandlerMetrics(m),
+		video.WithPanicTracker(panics),
+	}
+	if s.feedbackTopic != "" {
+		feedbackConfig, err := topicSink(s.sink, s.feedbackTopic)
+		if err != nil {
+			return fmt.Errorf("invalid feedback topic: %w", err)
 		}
-		producer, err := kafka.NewProducer(producerConfig)
-		defer func(producer *kafka.Producer) {
+		feedbackProducer, err := c.newSink(feedbackConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create %s sink for the feedback: %w", s.sink.Type, err)
+		}
+		defer func(producer sink.Sink) {
 			err := producer.Close()
 			if err != nil {
-				log.WithError(err).Error("Producer could not stop")
+				log.WithError(err).Error("Feedback producer could not stop")
 			}
-		}(producer)
-
+		}(feedbackProducer)
+		handlerOpts = append(handlerOpts, video.WithFeedbackProducer(feedbackProducer))
+	}
+	if s.deadLetterTopic != "" {
+		deadLetterConfig, err := topicSink(s.sink, s.deadLetterTopic)
 		if err != nil {
-			log.WithError(err).Fatal("Failed to create Kafka producer")
+			return fmt.Errorf("invalid dead letter topic: %w", err)
 		}
-
-		consumerConfig := kafka.ConsumerConfig{
-			ClusterArn:              clusterArn,
-			BrokersConnectionString: *kafkaAddress,
-			ConsumerGroup:           *group,
+		deadLetterProducer, err := c.newSink(deadLetterConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create %s sink for the dead letters: %w", s.sink.Type, err)
 		}
+		defer func(producer sink.Sink) {
+			err := producer.Close()
+			if err != nil {
+				log.WithError(err).Error("Dead letter producer could not stop")
+			}
+		}(deadLetterProducer)
+		handlerOpts = append(handlerOpts, video.WithDeadLetterProducer(deadLetterProducer))
+	}
 
-		topics := []*kafka.Topic{
-			kafka.NewTopic(*readTopic, kafka.WithLagTolerance(int64(*consumerLagTolerance))),
+	if s.dateValidation.Mode != video.DateValidationWarn && s.dateValidation.Mode != video.DateValidationFail {
+		return fmt.Errorf("unknown date validation %q", s.dateValidation.Mode)
+	}
+	mapperOpts := []video.MapperOption{
+		video.WithMapperMetrics(m),
+		video.WithParagraphGap(s.paragraphGap),
+		video.WithDurationTolerance(s.durationTolerance),
+		video.WithDateValidation(s.dateValidation),
+		video.WithTypeMapping(s.typeMapping),
+	}
+	if s.policyFile != "" {
+		policy, err := video.LoadPolicy(s.policyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load the publication policy: %w", err)
 		}
-		videoMapper := video.NewVideoMapper(log)
-		handler := video.NewRequestHandler(producer, videoMapper, log)
-		log.Info(prettyPrintConfig(consumerConfig, producerConfig, *readTopic))
+		mapperOpts = append(mapperOpts, video.WithPolicy(policy))
+	}
 
-		consumer, err := kafka.NewConsumer(consumerConfig, topics, log)
+	videoMapper := video.NewVideoMapper(log, mapperOpts...)
+	handler := video.NewRequestHandler(producer, videoMapper, log, handlerOpts...)
+	log.Info(prettyPrintConfig(s.source, s.sink))
 
-		if err != nil {
-			log.WithError(err).Fatal("Failed to create Kafka consumer")
-		}
+	consumer, err := c.newSource(s.source, log)
+	if err != nil {
+		return fmt.Errorf("failed to create %s source: %w", s.source.Type, err)
+	}
 
+	if rs, ok := consumer.(source.ResultSource); ok {
+		go rs.StartWithResult(handler.HandleMessage)
+	} else {
 		go consumer.Start(handler.OnMessage)
-		defer func(consumer *kafka.Consumer) {
-			err = consumer.Close()
-			if err != nil {
-				log.WithError(err).Error("Consumer could not stop")
-			}
-		}(consumer)
+	}
+	// the source is closed and drained before the mappings in flight are cancelled,
+	// otherwise the messages it delivers in between would be dropped
+	stopConsuming := func() {
+		if err := consumer.Close(); err != nil {
+			log.WithError(err).Error("Consumer could not stop")
+		}
+		handler.Shutdown()
+	}
 
-		hc := video.NewHealthCheck(producer, consumer, *appName, *appSystemCode)
-		go serveEndpoints(handler, hc, *appPort, log)
-		waitForSignal()
+	ingest, _ := consumer.(http.Handler)
+	hc := video.NewHealthCheck(producer, consumer, s.appName, s.appSystemCode, video.WithPanicCheck(panics))
+	server := &http.Server{Handler: newRouter(handler, hc, ingest, m.Handler())}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Infof("Starting to listen on [%s]", listener.Addr())
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stopConsuming()
+		return fmt.Errorf("couldn't serve HTTP: %w", err)
+	case <-ctx.Done():
 	}
+	stopConsuming()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("HTTP server could not stop")
+	}
+	return nil
+}
 
-	err := app.Run(os.Args)
-	if err != nil {
-		println(err)
+// topicSink returns the configuration of a sink like c, writing to topic instead of the topic of the mapped messages.
+// The file sink writes to its path suffixed with the topic. The other sinks have no topics to keep the messages apart.
+func topicSink(c sink.Config, topic string) (sink.Config, error) {
+	switch c.Type {
+	case sink.TypeKafka, "":
+		c.Kafka.Topic = topic
+	case sink.TypeFile:
+		c.FilePath = c.FilePath + "." + topic
+	default:
+		return sink.Config{}, fmt.Errorf("%s is only supported by the %s and %s sinks, not %s", topic, sink.TypeKafka, sink.TypeFile, c.Type)
 	}
+	return c, nil
 }
 
-func serveEndpoints(serviceHandler *video.VideoMapperHandler, hc *video.HealthCheck, port int, log *logger.UPPLogger) {
+func newRouter(serviceHandler *video.VideoMapperHandler, hc *video.HealthCheck, ingest http.Handler, metricsHandler http.Handler) http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/map", serviceHandler.MapRequest).Methods("POST")
+	r.HandleFunc("/map/explain", serviceHandler.ExplainRequest).Methods("POST")
+	if ingest != nil {
+		r.Handle("/ingest", ingest).Methods("POST")
+	}
 	r.HandleFunc("/__health", hc.Health())
+	r.Handle("/metrics", metricsHandler)
 	r.HandleFunc(httphandlers.BuildInfoPath, httphandlers.BuildInfoHandler)
 	r.HandleFunc(httphandlers.PingPath, httphandlers.PingHandler)
 	r.HandleFunc(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(hc.GTG))
-
-	http.Handle("/", r)
-	log.Infof("Starting to listen on port [%d]", port)
-	err := http.ListenAndServe(":"+strconv.Itoa(port), nil)
-	if err != nil {
-		log.Panicf("Couldn't set up HTTP listener: %+v\n", err)
-	}
-}
-
-func waitForSignal() {
-	ch := make(chan os.Signal)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	<-ch
+	return r
 }
 
-func prettyPrintConfig(c kafka.ConsumerConfig, p kafka.ProducerConfig, readTopic string) string {
-	return fmt.Sprintf("Config: [\n\t%s\n\t%s\n]", prettyPrintConsumerConfig(c, readTopic), prettyPrintProducerConfig(p))
+func prettyPrintConfig(c source.Config, s sink.Config) string {
+	return fmt.Sprintf("Config: [\n\t%s\n\t%s\n]", prettyPrintSourceConfig(c), prettyPrintSinkConfig(s))
 }
 
-func prettyPrintConsumerConfig(c kafka.ConsumerConfig, readTopic string) string {
-	return fmt.Sprintf("consumerConfig: [\n\t\taddr: [%v]\n\t\tgroup: [%v]\n\t\ttopic: [%v]\n\t\t]", c.BrokersConnectionString, c.ConsumerGroup, readTopic)
+func prettyPrintSourceConfig(c source.Config) string {
+	return fmt.Sprintf("sourceConfig: [\n\t\t%s\n\t\t]", c.Describe())
 }
 
-func prettyPrintProducerConfig(p kafka.ProducerConfig) string {
-	return fmt.Sprintf("producerConfig: [\n\t\taddr: [%v]\n\t\ttopic: [%v]\n\t\t]", p.BrokersConnectionString, p.Topic)
+func prettyPrintSinkConfig(s sink.Config) string {
+	return fmt.Sprintf("sinkConfig: [\n\t\t%s\n\t\t]", s.Describe())
 }
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/kafkatest"
+	"github.com/Financial-Times/upp-next-video-mapper/sink"
+	"github.com/Financial-Times/upp-next-video-mapper/source"
+	"github.com/Financial-Times/upp-next-video-mapper/video"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	readTopic     = "NativeCmsPublicationEvents"
+	writeTopic    = "CmsPublicationEvents"
+	feedbackTopic = "NextVideoMappingFeedback"
+)
+
+// client doesn't keep connections alive, so that they don't hold up the shutdown of the service.
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+func TestRun_EndToEnd(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	baseURL, stop := startService(t, broker)
+
+	native, err := os.ReadFile("video/test-resources/video-input.json")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(readTopic, kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_e2e",
+			"Message-Timestamp": "2017-04-13T10:27:32.353Z",
+			"Origin-System-Id":  "http://cmdb.ft.com/systems/next-video-editor",
+			"Content-Type":      "application/json",
+		},
+		Body: string(native),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	published, err := broker.WaitForMessages(ctx, writeTopic, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "tid_e2e", published[0].Headers["X-Request-Id"])
+	var event struct {
+		ContentURI string `json:"contentUri"`
+		Payload    struct {
+			UUID string `json:"uuid"`
+			Type string `json:"type"`
+		} `json:"payload"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(published[0].Body), &event))
+	assert.Equal(t, "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", event.Payload.UUID)
+	assert.Equal(t, video.DefaultTypeMapping.Video, event.Payload.Type)
+
+	feedback, err := broker.WaitForMessages(ctx, feedbackTopic, 1)
+	require.NoError(t, err)
+	assert.Contains(t, feedback[0].Body, "storyPackage")
+
+	status, body := httpGet(t, baseURL+"/metrics")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "next_video_mapper_messages_mapped_total 1")
+
+	res, err := client.Post(baseURL+"/map", "application/json", strings.NewReader(string(native)))
+	require.NoError(t, err)
+	mapped, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(mapped), `"uuid":"a40808ac-1417-4c48-9781-1dd2d8c8c6dc"`)
+
+	assert.NoError(t, stop(), "The service should stop cleanly")
+}
+
+func TestRun_HealthFollowsTheBroker(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	baseURL, stop := startService(t, broker)
+
+	status, body := httpGet(t, baseURL+"/__health")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"name":"Read Message Queue Reachable","ok":true`)
+	assert.Contains(t, body, `"name":"Write Message Queue Reachable","ok":true`)
+	assert.Contains(t, body, `"name":"Messages Are Mapped Without Panicking","ok":true`)
+
+	broker.SetUnreachable(errors.New("broker is down"))
+	status, _ = httpGet(t, baseURL+"/__gtg")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "The service shouldn't be good to go without its broker")
+	_, body = httpGet(t, baseURL+"/__health")
+	assert.Contains(t, body, `"name":"Read Message Queue Reachable","ok":false`)
+	assert.Contains(t, body, `"name":"Write Message Queue Reachable","ok":false`)
+
+	broker.SetUnreachable(nil)
+	status, _ = httpGet(t, baseURL+"/__gtg")
+	assert.Equal(t, http.StatusOK, status, "The service should be good to go once the broker is back")
+
+	assert.NoError(t, stop())
+}
+
+func TestRun_ShutdownClosesTheSourceFirst(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	native, err := os.ReadFile("video/test-resources/video-input.json")
+	require.NoError(t, err)
+	consumer := &drainingSource{
+		Consumer: broker.Consumer(readTopic),
+		started:  make(chan struct{}),
+		last: kafka.FTMessage{
+			Headers: map[string]string{
+				"X-Request-Id":      "tid_draining",
+				"Message-Timestamp": "2017-04-13T10:27:32.353Z",
+				"Origin-System-Id":  "http://cmdb.ft.com/systems/next-video-editor",
+				"Content-Type":      "application/json",
+			},
+			Body: string(native),
+		},
+	}
+	connectors := brokerConnectors(broker)
+	connectors.newSource = func(source.Config, *logger.UPPLogger) (source.Source, error) {
+		return consumer, nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, testSettings(), connectors, listener, logger.NewUPPLogger(serviceName, "Error"))
+	}()
+	select {
+	case <-consumer.started:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "The source should be started")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "The service didn't stop")
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	published, err := broker.WaitForMessages(waitCtx, writeTopic, 1)
+	require.NoError(t, err, "The message handled while the source drains should still be sent")
+	assert.Equal(t, "tid_draining", published[0].Headers["X-Request-Id"])
+}
+
+// drainingSource hands a last message to the handler while it is being closed, like a source
+// finishing the message in flight.
+type drainingSource struct {
+	*kafkatest.Consumer
+	started chan struct{}
+	handler func(kafka.FTMessage)
+	last    kafka.FTMessage
+}
+
+func (s *drainingSource) Start(handler func(kafka.FTMessage)) {
+	s.handler = handler
+	close(s.started)
+	s.Consumer.Start(handler)
+}
+
+func (s *drainingSource) Close() error {
+	<-s.started
+	s.handler(s.last)
+	return s.Consumer.Close()
+}
+
+func TestRun_InvalidSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings func(*settings)
+		err      string
+	}{
+		{
+			name:     "date validation",
+			settings: func(s *settings) { s.dateValidation.Mode = "ignore" },
+			err:      `unknown date validation "ignore"`,
+		},
+		{
+			name: "feedback without topics",
+			settings: func(s *settings) {
+				s.sink = sink.Config{Type: sink.TypeWebhook, WebhookURL: "http://localhost/content"}
+			},
+			err: "invalid feedback topic: NextVideoMappingFeedback is only supported by the kafka and file sinks, not webhook",
+		},
+		{
+			name: "dead letters without topics",
+			settings: func(s *settings) {
+				s.sink = sink.Config{Type: sink.TypeStdout}
+				s.feedbackTopic = ""
+				s.deadLetterTopic = "NextVideoMappingDeadLetters"
+			},
+			err: "invalid dead letter topic: NextVideoMappingDeadLetters is only supported by the kafka and file sinks, not stdout",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testSettings()
+			test.settings(&s)
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer listener.Close()
+
+			err = run(context.Background(), s, brokerConnectors(kafkatest.NewBroker()), listener, logger.NewUPPLogger(serviceName, "Error"))
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+// startService runs the service against broker until the returned function stops it.
+func startService(t *testing.T, broker *kafkatest.Broker) (string, func() error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, testSettings(), brokerConnectors(broker), listener, logger.NewUPPLogger(serviceName, "Error"))
+	}()
+
+	baseURL := "http://" + listener.Addr().String()
+	require.Eventually(t, func() bool {
+		res, err := client.Get(baseURL + "/__gtg")
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, "The service should become good to go")
+
+	stopped := false
+	stop := func() error {
+		if stopped {
+			return nil
+		}
+		stopped = true
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("the service didn't stop")
+		}
+	}
+	t.Cleanup(func() { _ = stop() })
+	return baseURL, stop
+}
+
+func testSettings() settings {
+	return settings{
+		appName:       "Next Video Mapper",
+		appSystemCode: "next-video-mapper",
+		sink: sink.Config{
+			Type:  sink.TypeKafka,
+			Kafka: kafka.ProducerConfig{Topic: writeTopic},
+		},
+		source: source.Config{
+			Type:       source.TypeKafka,
+			KafkaTopic: readTopic,
+		},
+		feedbackTopic:  feedbackTopic,
+		panicThreshold: 0,
+		panicWindow:    time.Minute,
+		paragraphGap:   2 * time.Second,
+		dateValidation: video.DateValidation{Mode: video.DateValidationWarn, MaxFutureSkew: 5 * time.Minute},
+		typeMapping:    video.DefaultTypeMapping,
+	}
+}
+
+func brokerConnectors(broker *kafkatest.Broker) connectors {
+	return connectors{
+		newSource: func(c source.Config, _ *logger.UPPLogger) (source.Source, error) {
+			return broker.Consumer(c.KafkaTopic), nil
+		},
+		newSink: func(c sink.Config) (sink.Sink, error) {
+			return broker.Producer(c.Kafka.Topic), nil
+		},
+	}
+}
+
+func httpGet(t *testing.T, url string) (int, string) {
+	res, err := client.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(body)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "next_video_mapper"
+
+// Prometheus collects the metrics of the mapping pipeline in its own registry and serves them on /metrics.
+type Prometheus struct {
+	registry        *prometheus.Registry
+	consumed        prometheus.Counter
+	mapped          prometheus.Counter
+	skipped         *prometheus.CounterVec
+	failed          *prometheus.CounterVec
+	producerLatency prometheus.Histogram
+	producerErrors  prometheus.Counter
+	mappingDuration prometheus.Histogram
+	warnings        *prometheus.CounterVec
+	panics          prometheus.Counter
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		consumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_consumed_total",
+			Help:      "Messages received from the source.",
+		}),
+		mapped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_mapped_total",
+			Help:      "Messages mapped and sent to the sink.",
+		}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_skipped_total",
+			Help:      "Messages not mapped on purpose, by reason.",
+		}, []string{"reason"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_failed_total",
+			Help:      "Messages that couldn't be mapped or sent, by error class.",
+		}, []string{"class"}),
+		producerLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "producer_latency_seconds",
+			Help:      "Time taken to send a mapped message to the sink.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		producerErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "producer_errors_total",
+			Help:      "Mapped messages the sink failed to accept.",
+		}),
+		mappingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "mapping_duration_seconds",
+			Help:      "Time taken to map a native message.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}),
+		warnings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mapping_warnings_total",
+			Help:      "Problems found in native videos while mapping, by output field.",
+		}, []string{"field"}),
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "panics_total",
+			Help:      "Messages whose handling panicked and was recovered.",
+		}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.consumed,
+		p.mapped,
+		p.skipped,
+		p.failed,
+		p.producerLatency,
+		p.producerErrors,
+		p.mappingDuration,
+		p.warnings,
+		p.panics,
+	)
+	return p
+}
+
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+func (p *Prometheus) MessageConsumed() {
+	p.consumed.Inc()
+}
+
+func (p *Prometheus) MessageMapped() {
+	p.mapped.Inc()
+}
+
+func (p *Prometheus) MessageSkipped(reason string) {
+	p.skipped.WithLabelValues(reason).Inc()
+}
+
+func (p *Prometheus) MessageFailed(class string) {
+	p.failed.WithLabelValues(class).Inc()
+}
+
+func (p *Prometheus) MessageProduced(d time.Duration, err error) {
+	p.producerLatency.Observe(d.Seconds())
+	if err != nil {
+		p.producerErrors.Inc()
+	}
+}
+
+func (p *Prometheus) MappingDuration(d time.Duration) {
+	p.mappingDuration.Observe(d.Seconds())
+}
+
+func (p *Prometheus) MappingWarning(field string) {
+	p.warnings.WithLabelValues(field).Inc()
+}
+
+func (p *Prometheus) MessagePanicked() {
+	p.panics.Inc()
+}
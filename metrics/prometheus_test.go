@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus_Handler(t *testing.T) {
+	p := NewPrometheus()
+	p.MessageConsumed()
+	p.MessageSkipped("origin")
+	p.MessageFailed("invalid_json")
+	p.MessageProduced(10*time.Millisecond, errors.New("broken"))
+	p.MappingDuration(time.Millisecond)
+	p.MappingWarning("mainImage")
+	p.MessagePanicked()
+
+	w := httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "next_video_mapper_messages_consumed_total 1")
+	assert.Contains(t, body, `next_video_mapper_messages_skipped_total{reason="origin"} 1`)
+	assert.Contains(t, body, `next_video_mapper_messages_failed_total{class="invalid_json"} 1`)
+	assert.Contains(t, body, "next_video_mapper_producer_errors_total 1")
+	assert.Contains(t, body, "next_video_mapper_producer_latency_seconds_count 1")
+	assert.Contains(t, body, "next_video_mapper_mapping_duration_seconds_count 1")
+	assert.Contains(t, body, `next_video_mapper_mapping_warnings_total{field="mainImage"} 1`)
+	assert.Contains(t, body, "next_video_mapper_panics_total 1")
+}
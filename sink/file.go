@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+const (
+	defaultFileMaxBytes   = 100 * 1024 * 1024
+	defaultFileMaxBackups = 5
+)
+
+// File appends every message as a NDJSON envelope line to a file. When the file would grow
+// over maxBytes it is rotated to path.1, path.1 to path.2 and so on, keeping maxBackups files.
+type File struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	lastErr    error
+}
+
+func NewFile(path string, maxBytes int64, maxBackups int) (*File, error) {
+	if path == "" {
+		return nil, errors.New("no path provided for the file sink")
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultFileMaxBytes
+	}
+	if maxBackups < 0 {
+		maxBackups = defaultFileMaxBackups
+	}
+
+	f := &File{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) SendMessage(m kafka.FTMessage) error {
+	line, err := utils.MarshalEnvelope(m)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal message envelope: %w", err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastErr = f.write(line)
+	return f.lastErr
+}
+
+func (f *File) write(line []byte) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.size > 0 && f.size+int64(len(line)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("couldn't rotate %s: %w", f.path, err)
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("couldn't stat %s: %w", f.path, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return f.open()
+	}
+
+	for i := f.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(backupName(f.path, i), backupName(f.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, backupName(f.path, 1)); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func (f *File) ConnectivityCheck() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastErr != nil {
+		return fmt.Errorf("last write to %s failed: %w", f.path, f.lastErr)
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_SendMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	f, err := NewFile(path, 0, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, f.SendMessage(kafka.FTMessage{Headers: map[string]string{"X-Request-Id": "tid_1"}, Body: "{}"}))
+	require.NoError(t, f.SendMessage(kafka.FTMessage{Headers: map[string]string{"X-Request-Id": "tid_2"}, Body: "{}"}))
+	require.NoError(t, f.Close())
+
+	lines := readLines(t, path)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "tid_1", lines[0].Headers["X-Request-Id"])
+		assert.Equal(t, "tid_2", lines[1].Headers["X-Request-Id"])
+	}
+	assert.NoError(t, f.ConnectivityCheck())
+}
+
+func TestFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	m := kafka.FTMessage{Headers: map[string]string{"X-Request-Id": "tid_123123"}, Body: "{}"}
+	line, _ := utils.MarshalEnvelope(m)
+
+	f, err := NewFile(path, int64(len(line)+1), 2)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, f.SendMessage(m))
+	}
+	require.NoError(t, f.Close())
+
+	assert.Len(t, readLines(t, path), 1)
+	assert.Len(t, readLines(t, path+".1"), 1)
+	assert.Len(t, readLines(t, path+".2"), 1)
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFile_UnwritablePath(t *testing.T) {
+	_, err := NewFile(filepath.Join(t.TempDir(), "missing", "out.ndjson"), 0, 1)
+	assert.Error(t, err)
+}
+
+func readLines(t *testing.T, path string) []utils.Envelope {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var envelopes []utils.Envelope
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e utils.Envelope
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		envelopes = append(envelopes, e)
+	}
+	return envelopes
+}
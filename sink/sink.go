@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const (
+	TypeKafka   = "kafka"
+	TypeStdout  = "stdout"
+	TypeFile    = "file"
+	TypeWebhook = "webhook"
+)
+
+// Sink is where mapped messages are written to. Every implementation reports its own health
+// through ConnectivityCheck, so it can be plugged in as the producer of the health check.
+type Sink interface {
+	SendMessage(kafka.FTMessage) error
+	ConnectivityCheck() error
+	Close() error
+}
+
+type Config struct {
+	Type                 string
+	Kafka                kafka.ProducerConfig
+	FilePath             string
+	FileMaxBytes         int64
+	FileMaxBackups       int
+	WebhookURL           string
+	WebhookAuthorization string
+	WebhookTimeout       time.Duration
+}
+
+func New(c Config) (Sink, error) {
+	switch c.Type {
+	case TypeKafka, "":
+		if c.Kafka.BrokersConnectionString == "" {
+			return nil, fmt.Errorf("no kafka address provided for the %s sink", TypeKafka)
+		}
+		producer, err := kafka.NewProducer(c.Kafka)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+		}
+		return producer, nil
+	case TypeStdout:
+		return NewStdout(os.Stdout), nil
+	case TypeFile:
+		return NewFile(c.FilePath, c.FileMaxBytes, c.FileMaxBackups)
+	case TypeWebhook:
+		if c.WebhookURL == "" {
+			return nil, fmt.Errorf("no URL provided for the %s sink", TypeWebhook)
+		}
+		return NewWebhook(c.WebhookURL, c.WebhookAuthorization, &http.Client{Timeout: c.WebhookTimeout}), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", c.Type)
+	}
+}
+
+// Describe returns a one line description of the sink configuration for the startup logs.
+func (c Config) Describe() string {
+	switch c.Type {
+	case TypeKafka, "":
+		return fmt.Sprintf("kafka: [addr: [%v] topic: [%v]]", c.Kafka.BrokersConnectionString, c.Kafka.Topic)
+	case TypeFile:
+		return fmt.Sprintf("file: [path: [%v] maxBytes: [%v] maxBackups: [%v]]", c.FilePath, c.FileMaxBytes, c.FileMaxBackups)
+	case TypeWebhook:
+		return fmt.Sprintf("webhook: [url: [%v]]", c.WebhookURL)
+	default:
+		return c.Type
+	}
+}
@@ -0,0 +1,40 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+// Stdout writes every message as a single NDJSON envelope line.
+type Stdout struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdout(w io.Writer) *Stdout {
+	return &Stdout{w: w}
+}
+
+func (s *Stdout) SendMessage(m kafka.FTMessage) error {
+	line, err := utils.MarshalEnvelope(m)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal message envelope: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func (s *Stdout) ConnectivityCheck() error {
+	return nil
+}
+
+func (s *Stdout) Close() error {
+	return nil
+}
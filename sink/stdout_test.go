@@ -0,0 +1,23 @@
+package sink
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdout_SendMessage(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStdout(&buf)
+
+	err := s.SendMessage(kafka.FTMessage{
+		Headers: map[string]string{"X-Request-Id": "tid_123123"},
+		Body:    `{"title":"<b>x</b>"}`,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"headers":{"X-Request-Id":"tid_123123"},"body":"{\"title\":\"<b>x</b>\"}"}`+"\n", buf.String())
+	assert.NoError(t, s.ConnectivityCheck())
+}
@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+// Webhook POSTs the body of every message to a URL, passing the message headers as HTTP headers.
+type Webhook struct {
+	url           string
+	authorization string
+	client        *http.Client
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func NewWebhook(url string, authorization string, client *http.Client) *Webhook {
+	return &Webhook{
+		url:           url,
+		authorization: authorization,
+		client:        client,
+	}
+}
+
+func (w *Webhook) SendMessage(m kafka.FTMessage) error {
+	err := w.post(m)
+
+	w.mu.Lock()
+	w.lastErr = err
+	w.mu.Unlock()
+	return err
+}
+
+func (w *Webhook) post(m kafka.FTMessage) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, strings.NewReader(m.Body))
+	if err != nil {
+		return err
+	}
+	for k, v := range m.Headers {
+		req.Header.Set(k, v)
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if w.authorization != "" {
+		req.Header.Set("Authorization", w.authorization)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't post message to %s: %w", w.url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with status %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// ConnectivityCheck reports the outcome of the last delivery, as the webhook is only known to be
+// reachable when messages are sent to it.
+func (w *Webhook) ConnectivityCheck() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+func (w *Webhook) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_SendMessage(t *testing.T) {
+	var body, tid, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		tid = r.Header.Get("X-Request-Id")
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	w := NewWebhook(server.URL, "Basic secret", server.Client())
+	err := w.SendMessage(kafka.FTMessage{Headers: map[string]string{"X-Request-Id": "tid_123123"}, Body: `{"uuid":"x"}`})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"uuid":"x"}`, body)
+	assert.Equal(t, "tid_123123", tid)
+	assert.Equal(t, "Basic secret", auth)
+	assert.NoError(t, w.ConnectivityCheck())
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	w := NewWebhook(server.URL, "", server.Client())
+	err := w.SendMessage(kafka.FTMessage{Body: "{}"})
+
+	assert.EqualError(t, err, "webhook "+server.URL+" responded with status 503")
+	assert.Error(t, w.ConnectivityCheck(), "Failed delivery should make the sink unhealthy")
+}
@@ -0,0 +1,219 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+const (
+	processedDir        = "processed"
+	failedDir           = "failed"
+	defaultPollInterval = 5 * time.Second
+)
+
+// Directory watches a directory for *.json files holding message envelopes.
+// Every file is handled once and then moved to the processed subdirectory, or to the failed one
+// when it cannot be read or its message was not handled. Files modified within the last poll interval
+// are left for a later poll, as they may still be being written.
+type Directory struct {
+	dir              string
+	pollInterval     time.Duration
+	pendingTolerance int
+	log              *logger.UPPLogger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	// handling is held while a file is handled, so that Close can wait for it.
+	handling sync.Mutex
+	// stuck holds the files that couldn't be moved once handled, which are not handled again.
+	stuck map[string]bool
+}
+
+func NewDirectory(dir string, pollInterval time.Duration, pendingTolerance int, log *logger.UPPLogger) (*Directory, error) {
+	if dir == "" {
+		return nil, errors.New("no directory provided for the file source")
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	for _, sub := range []string{processedDir, failedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("couldn't create %s directory: %w", sub, err)
+		}
+	}
+
+	return &Directory{
+		dir:              dir,
+		pollInterval:     pollInterval,
+		pendingTolerance: pendingTolerance,
+		log:              log,
+		stop:             make(chan struct{}),
+		stuck:            map[string]bool{},
+	}, nil
+}
+
+// Start handles the pending files every poll interval until the source is closed.
+func (d *Directory) Start(handler func(kafka.FTMessage)) {
+	d.StartWithResult(ignoreResult(handler))
+}
+
+// StartWithResult is like Start, moving the files whose message handler returned an error to the failed subdirectory.
+func (d *Directory) StartWithResult(handler func(kafka.FTMessage) error) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.poll(handler)
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Directory) poll(handler func(kafka.FTMessage) error) {
+	files, err := d.pending()
+	if err != nil {
+		d.log.WithError(err).Errorf("Couldn't list files in %s", d.dir)
+		return
+	}
+
+	// a stuck file is forgotten once it is gone, so that a new file with the same name is handled
+	stuck := make(map[string]bool, len(d.stuck))
+	for _, name := range files {
+		if d.stuck[name] {
+			stuck[name] = true
+		}
+	}
+	d.stuck = stuck
+
+	for _, name := range files {
+		if d.stuck[name] || !d.settled(name) {
+			continue
+		}
+		if !d.handle(handler, name) {
+			return
+		}
+	}
+}
+
+// settled tells whether the file was last modified more than a poll interval ago.
+func (d *Directory) settled(name string) bool {
+	info, err := os.Stat(filepath.Join(d.dir, name))
+	return err == nil && time.Since(info.ModTime()) >= d.pollInterval
+}
+
+// handle hands the message of a file to handler, and reports false when the source was closed first.
+func (d *Directory) handle(handler func(kafka.FTMessage) error, name string) bool {
+	d.handling.Lock()
+	defer d.handling.Unlock()
+	select {
+	case <-d.stop:
+		return false
+	default:
+	}
+
+	m, err := readEnvelope(filepath.Join(d.dir, name))
+	if err != nil {
+		d.log.WithError(err).Errorf("Couldn't read message from %s", name)
+		d.move(name, failedDir)
+		return true
+	}
+
+	if err := handler(m); err != nil {
+		d.log.WithTransactionID(m.Headers["X-Request-Id"]).WithError(err).Errorf("Couldn't handle message from %s", name)
+		d.move(name, failedDir)
+		return true
+	}
+	d.move(name, processedDir)
+	return true
+}
+
+func (d *Directory) pending() ([]string, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (d *Directory) move(name string, subdir string) {
+	err := os.Rename(filepath.Join(d.dir, name), filepath.Join(d.dir, subdir, name))
+	if err != nil {
+		d.log.WithError(err).Errorf("Couldn't move %s to %s, it won't be handled again until it is removed", name, subdir)
+		d.stuck[name] = true
+	}
+}
+
+func readEnvelope(path string) (kafka.FTMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return kafka.FTMessage{}, err
+	}
+
+	var e utils.Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return kafka.FTMessage{}, fmt.Errorf("invalid message envelope: %w", err)
+	}
+
+	m := e.FTMessage()
+	if m.Headers["X-Request-Id"] == "" {
+		m.Headers["X-Request-Id"] = tid.NewTransactionID()
+	}
+	return m, nil
+}
+
+func (d *Directory) ConnectivityCheck() error {
+	info, err := os.Stat(d.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", d.dir)
+	}
+	return nil
+}
+
+// MonitorCheck fails when more files are waiting than the configured tolerance.
+func (d *Directory) MonitorCheck() error {
+	if d.pendingTolerance <= 0 {
+		return nil
+	}
+	files, err := d.pending()
+	if err != nil {
+		return err
+	}
+	if len(files) > d.pendingTolerance {
+		return fmt.Errorf("%d files are waiting in %s", len(files), d.dir)
+	}
+	return nil
+}
+
+// Close stops the polling, and waits for the file being handled.
+func (d *Directory) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	d.handling.Lock()
+	defer d.handling.Unlock()
+	return nil
+}
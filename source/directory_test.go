@@ -0,0 +1,185 @@
+package source
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory_Start(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"headers":{"X-Request-Id":"tid_a","Origin-System-Id":"origin"},"body":{"id":"a"}}`)
+	writeFile(t, dir, "b.json", `{"headers":{},"body":"{\"id\":\"b\"}"}`)
+	writeFile(t, dir, "c.json", `not json`)
+	writeFile(t, dir, "ignored.txt", `{}`)
+
+	d, err := NewDirectory(dir, 10*time.Millisecond, 0, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var received []kafka.FTMessage
+	go d.Start(func(m kafka.FTMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, m)
+	})
+	defer d.Close()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, failedDir, "c.json"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "Invalid envelope should be moved to the failed directory")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, "tid_a", received[0].Headers["X-Request-Id"])
+		assert.Equal(t, `{"id":"a"}`, received[0].Body)
+		assert.NotEmpty(t, received[1].Headers["X-Request-Id"], "Transaction ID should be generated when missing")
+		assert.Equal(t, `{"id":"b"}`, received[1].Body)
+	}
+	assert.FileExists(t, filepath.Join(dir, processedDir, "a.json"))
+	assert.FileExists(t, filepath.Join(dir, processedDir, "b.json"))
+	assert.FileExists(t, filepath.Join(dir, "ignored.txt"))
+}
+
+func TestDirectory_StartWithResult(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"headers":{},"body":{"id":"a"}}`)
+	writeFile(t, dir, "b.json", `{"headers":{},"body":{"id":"b"}}`)
+
+	d, err := NewDirectory(dir, 10*time.Millisecond, 0, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	go d.StartWithResult(func(m kafka.FTMessage) error {
+		if m.Body == `{"id":"b"}` {
+			return errors.New("mapping failed")
+		}
+		return nil
+	})
+	defer d.Close()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, failedDir, "b.json"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "A file whose message was not handled should be moved to the failed directory")
+	assert.FileExists(t, filepath.Join(dir, processedDir, "a.json"))
+}
+
+func TestDirectory_SkipsFilesBeingWritten(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"headers":{},"body":{"id":"a"}}`)
+	// a file modified a moment ago, seen from a later poll
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.json"), time.Now(), time.Now().Add(time.Hour)))
+
+	d, err := NewDirectory(dir, 10*time.Millisecond, 0, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	handled := 0
+	go d.Start(func(kafka.FTMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+	})
+	defer d.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Zero(t, handled, "A file modified within the poll interval may be incomplete")
+	mu.Unlock()
+
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.json"), time.Now(), time.Now().Add(-time.Second)))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, processedDir, "a.json"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "The file should be handled once it is no longer modified")
+}
+
+func TestDirectory_SkipsFilesThatCannotBeMoved(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"headers":{},"body":{"id":"a"}}`)
+
+	d, err := NewDirectory(dir, 10*time.Millisecond, 0, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+	// moving to processed/ fails once it is a file
+	require.NoError(t, os.Remove(filepath.Join(dir, processedDir)))
+	writeFile(t, dir, processedDir, "")
+
+	var mu sync.Mutex
+	handled := 0
+	go d.Start(func(kafka.FTMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+	})
+	defer d.Close()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled > 0
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, handled, "A file that couldn't be moved should not be published again")
+	assert.FileExists(t, filepath.Join(dir, "a.json"))
+}
+
+func TestDirectory_CloseWaitsForTheHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"headers":{},"body":{"id":"a"}}`)
+	writeFile(t, dir, "b.json", `{"headers":{},"body":{"id":"b"}}`)
+
+	d, err := NewDirectory(dir, 10*time.Millisecond, 0, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	go d.Start(func(kafka.FTMessage) {
+		close(handling)
+		<-release
+	})
+	<-handling
+
+	closed := make(chan struct{})
+	go func() {
+		_ = d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close should wait for the file being handled")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-closed
+	assert.FileExists(t, filepath.Join(dir, processedDir, "a.json"))
+	assert.FileExists(t, filepath.Join(dir, "b.json"), "No file should be handled once the source is closed")
+}
+
+func TestDirectory_MonitorCheck(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirectory(dir, time.Second, 1, logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	writeFile(t, dir, "a.json", `{}`)
+	assert.NoError(t, d.MonitorCheck())
+	writeFile(t, dir, "b.json", `{}`)
+	assert.Error(t, d.MonitorCheck(), "Too many pending files should be reported")
+	assert.NoError(t, d.ConnectivityCheck())
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
@@ -0,0 +1,161 @@
+package source
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+const maxIngestBodySize = 10 * 1024 * 1024
+
+// HTTP is an ingest endpoint taking message envelopes, either a single one or an array of them.
+// Requests have to send the configured API key in the X-Api-Key header. They are answered with
+// 202 Accepted once all their messages are handled, 422 Unprocessable Entity when some of them
+// were rejected, and 500 Internal Server Error when some could not be handled.
+type HTTP struct {
+	apiKey string
+	log    *logger.UPPLogger
+
+	// mu is read-locked while a request is handled, so that Close waits for the requests in flight.
+	mu      sync.RWMutex
+	handler func(kafka.FTMessage) error
+}
+
+// rejection is implemented by the handler errors telling whether the message itself is at fault,
+// rather than the service.
+type rejection interface {
+	Rejected() bool
+}
+
+func NewHTTP(apiKey string, log *logger.UPPLogger) (*HTTP, error) {
+	if apiKey == "" {
+		return nil, errors.New("no API key provided for the http source")
+	}
+	return &HTTP{
+		apiKey: apiKey,
+		log:    log,
+	}, nil
+}
+
+func (h *HTTP) Start(handler func(kafka.FTMessage)) {
+	h.StartWithResult(ignoreResult(handler))
+}
+
+// StartWithResult is like Start, answering an error status to the requests whose messages the handler returned an error for.
+func (h *HTTP) StartWithResult(handler func(kafka.FTMessage) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler = handler
+}
+
+func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	transactionID := tid.GetTransactionIDFromRequest(r)
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Api-Key")), []byte(h.apiKey)) != 1 {
+		http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	handler := h.handler
+	if handler == nil {
+		http.Error(w, "ingest is not started", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	envelopes, err := parseEnvelopes(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusAccepted
+	var problems []string
+	for i, e := range envelopes {
+		m := e.FTMessage()
+		if m.Headers["X-Request-Id"] == "" {
+			m.Headers["X-Request-Id"] = transactionID
+		}
+		err := handler(m)
+		if err == nil {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("message %d: %v", i, err))
+		var r rejection
+		if errors.As(err, &r) && r.Rejected() {
+			if status == http.StatusAccepted {
+				status = http.StatusUnprocessableEntity
+			}
+		} else {
+			status = http.StatusInternalServerError
+		}
+	}
+
+	if len(problems) > 0 {
+		h.log.WithTransactionID(transactionID).
+			Warnf("%d of %d ingested messages were not handled", len(problems), len(envelopes))
+		http.Error(w, strings.Join(problems, "\n"), status)
+		return
+	}
+	h.log.WithTransactionID(transactionID).Infof("Ingested %d messages", len(envelopes))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func parseEnvelopes(body []byte) ([]utils.Envelope, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("empty request body")
+	}
+
+	if body[0] == '[' {
+		var envelopes []utils.Envelope
+		if err := json.Unmarshal(body, &envelopes); err != nil {
+			return nil, fmt.Errorf("invalid message envelopes: %w", err)
+		}
+		return envelopes, nil
+	}
+
+	var e utils.Envelope
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("invalid message envelope: %w", err)
+	}
+	return []utils.Envelope{e}, nil
+}
+
+func (h *HTTP) ConnectivityCheck() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.handler == nil {
+		return errors.New("ingest endpoint is not started")
+	}
+	return nil
+}
+
+func (h *HTTP) MonitorCheck() error {
+	return nil
+}
+
+// Close stops the ingest, once the requests in flight are handled.
+func (h *HTTP) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler = nil
+	return nil
+}
@@ -0,0 +1,124 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP_Ingest(t *testing.T) {
+	h, err := NewHTTP("secret", logger.NewUPPLogger("video-mapper", "Debug"))
+	require.NoError(t, err)
+
+	var received []kafka.FTMessage
+	h.Start(func(m kafka.FTMessage) {
+		received = append(received, m)
+	})
+
+	body := `[{"headers":{"X-Request-Id":"tid_1"},"body":{"id":"a"}},{"headers":{"Content-Type":"application/json"},"body":"{}"}]`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-Request-Id", "tid_request")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, "tid_1", received[0].Headers["X-Request-Id"])
+		assert.Equal(t, `{"id":"a"}`, received[0].Body)
+		assert.Equal(t, "tid_request", received[1].Headers["X-Request-Id"], "Request transaction ID should be used when the envelope has none")
+	}
+}
+
+type rejectedError struct{}
+
+func (rejectedError) Error() string  { return "invalid message" }
+func (rejectedError) Rejected() bool { return true }
+
+func TestHTTP_HandlerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		expected int
+	}{
+		{name: "handled", errs: []error{nil, nil}, expected: http.StatusAccepted},
+		{name: "rejected", errs: []error{nil, rejectedError{}}, expected: http.StatusUnprocessableEntity},
+		{name: "failed", errs: []error{errors.New("broker is down"), rejectedError{}}, expected: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, _ := NewHTTP("secret", logger.NewUPPLogger("video-mapper", "Debug"))
+			handled := 0
+			h.StartWithResult(func(kafka.FTMessage) error {
+				err := test.errs[handled]
+				handled++
+				return err
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`[{"body":"{}"},{"body":"{}"}]`))
+			req.Header.Set("X-Api-Key", "secret")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expected, w.Code)
+			assert.Equal(t, 2, handled, "Every message should be handled")
+			for i, err := range test.errs {
+				if err != nil {
+					assert.Contains(t, w.Body.String(), fmt.Sprintf("message %d: %v", i, err))
+				}
+			}
+		})
+	}
+}
+
+func TestHTTP_Unauthorized(t *testing.T) {
+	h, _ := NewHTTP("secret", logger.NewUPPLogger("video-mapper", "Debug"))
+	called := false
+	h.Start(func(m kafka.FTMessage) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"body":"{}"}`))
+	req.Header.Set("X-Api-Key", "wrong")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, called)
+}
+
+func TestHTTP_InvalidEnvelope(t *testing.T) {
+	h, _ := NewHTTP("secret", logger.NewUPPLogger("video-mapper", "Debug"))
+	h.Start(func(m kafka.FTMessage) {})
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"body":`))
+	req.Header.Set("X-Api-Key", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHTTP_NotStarted(t *testing.T) {
+	h, _ := NewHTTP("secret", logger.NewUPPLogger("video-mapper", "Debug"))
+	assert.Error(t, h.ConnectivityCheck())
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"body":"{}"}`))
+	req.Header.Set("X-Api-Key", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestNewHTTP_RequiresAPIKey(t *testing.T) {
+	_, err := NewHTTP("", logger.NewUPPLogger("video-mapper", "Debug"))
+	assert.Error(t, err)
+}
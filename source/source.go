@@ -0,0 +1,85 @@
+package source
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const (
+	TypeKafka = "kafka"
+	TypeFile  = "file"
+	TypeHTTP  = "http"
+)
+
+// Source feeds native messages to a handler. Every implementation reports its own health
+// through ConnectivityCheck and MonitorCheck, so it can be plugged in as the consumer of the health check.
+type Source interface {
+	Start(handler func(kafka.FTMessage))
+	ConnectivityCheck() error
+	MonitorCheck() error
+	Close() error
+}
+
+// ResultSource is a Source acting on whether each message was handled, the handler returning an error
+// for the messages it rejected or could not handle.
+type ResultSource interface {
+	Source
+	StartWithResult(handler func(kafka.FTMessage) error)
+}
+
+// ignoreResult adapts a handler without result, for the sources started with Start.
+func ignoreResult(handler func(kafka.FTMessage)) func(kafka.FTMessage) error {
+	return func(m kafka.FTMessage) error {
+		handler(m)
+		return nil
+	}
+}
+
+type Config struct {
+	Type              string
+	Kafka             kafka.ConsumerConfig
+	KafkaTopic        string
+	KafkaLagTolerance int64
+	Directory         string
+	PollInterval      time.Duration
+	PendingTolerance  int
+	IngestAPIKey      string
+}
+
+func New(c Config, log *logger.UPPLogger) (Source, error) {
+	switch c.Type {
+	case TypeKafka, "":
+		if c.Kafka.BrokersConnectionString == "" {
+			return nil, fmt.Errorf("no kafka address provided for the %s source", TypeKafka)
+		}
+		topics := []*kafka.Topic{
+			kafka.NewTopic(c.KafkaTopic, kafka.WithLagTolerance(c.KafkaLagTolerance)),
+		}
+		consumer, err := kafka.NewConsumer(c.Kafka, topics, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+		}
+		return consumer, nil
+	case TypeFile:
+		return NewDirectory(c.Directory, c.PollInterval, c.PendingTolerance, log)
+	case TypeHTTP:
+		return NewHTTP(c.IngestAPIKey, log)
+	default:
+		return nil, fmt.Errorf("unknown source type %q", c.Type)
+	}
+}
+
+// Describe returns a one line description of the source configuration for the startup logs.
+func (c Config) Describe() string {
+	switch c.Type {
+	case TypeKafka, "":
+		return fmt.Sprintf("kafka: [addr: [%v] group: [%v] topic: [%v]]", c.Kafka.BrokersConnectionString, c.Kafka.ConsumerGroup, c.KafkaTopic)
+	case TypeFile:
+		return fmt.Sprintf("file: [directory: [%v] pollInterval: [%v]]", c.Directory, c.PollInterval)
+	default:
+		return c.Type
+	}
+}
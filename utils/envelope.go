@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+// Envelope is the JSON representation of a kafka.FTMessage used by the non-Kafka sinks and sources.
+type Envelope struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func NewEnvelope(m kafka.FTMessage) Envelope {
+	return Envelope{
+		Headers: m.Headers,
+		Body:    m.Body,
+	}
+}
+
+func (e Envelope) FTMessage() kafka.FTMessage {
+	headers := e.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	return kafka.FTMessage{Headers: headers, Body: e.Body}
+}
+
+// UnmarshalJSON accepts the body either as a JSON string or as an inline JSON document,
+// so native videos can be pushed without escaping them first.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	e.Headers = raw.Headers
+	e.Body = ""
+	body := bytes.TrimSpace(raw.Body)
+	switch {
+	case len(body) == 0 || bytes.Equal(body, []byte("null")):
+	case body[0] == '"':
+		if err := json.Unmarshal(body, &e.Body); err != nil {
+			return err
+		}
+	default:
+		e.Body = string(body)
+	}
+	return nil
+}
+
+// MarshalEnvelope returns the single line NDJSON form of a message.
+func MarshalEnvelope(m kafka.FTMessage) ([]byte, error) {
+	return UnsafeJSONMarshal(NewEnvelope(m))
+}
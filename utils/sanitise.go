@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// BodyXMLElements are the elements UPP bodyXML accepts in transcripts, with the attributes allowed on each.
+var BodyXMLElements = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"strong":     nil,
+	"em":         nil,
+	"b":          nil,
+	"i":          nil,
+	"sub":        nil,
+	"sup":        nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"ul":         nil,
+	"ol":         nil,
+	"li":         nil,
+	"blockquote": nil,
+	"a":          {"href", "title"},
+}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"head":     true,
+}
+
+// wrapperElements are unwrapped without being reported, as the sanitised transcript gets its own body.
+var wrapperElements = map[string]bool{
+	"html": true,
+	"body": true,
+}
+
+var voidElements = map[string]bool{
+	"br": true,
+}
+
+var entityRegexp = regexp.MustCompile(`&([a-zA-Z][a-zA-Z0-9]*);?`)
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+var xmlEntities = map[string]bool{
+	"amp":  true,
+	"lt":   true,
+	"gt":   true,
+	"quot": true,
+	"apos": true,
+}
+
+type sanitiser struct {
+	out     strings.Builder
+	open    []string
+	changes []string
+	seen    map[string]bool
+}
+
+func (s *sanitiser) change(format string, args ...interface{}) {
+	c := fmt.Sprintf(format, args...)
+	if s.seen[c] {
+		return
+	}
+	s.seen[c] = true
+	s.changes = append(s.changes, c)
+}
+
+// SanitiseTranscript turns an HTML transcript into UPP bodyXML wrapped in a body element.
+// Unclosed tags are closed, HTML entities are turned into characters, and elements and attributes
+// outside BodyXMLElements are removed, keeping their text. It returns the sanitised transcript,
+// empty when there is no text left, and a description of every change made.
+func SanitiseTranscript(data string) (string, []string) {
+	s := &sanitiser{seen: map[string]bool{}}
+	if valid := strings.Map(xmlCharacter, data); valid != data {
+		s.change("removed characters not allowed in XML")
+		data = valid
+	}
+	skip := ""
+	z := html.NewTokenizer(strings.NewReader(data))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				s.change("stopped at unreadable markup")
+			}
+			break
+		}
+
+		raw := string(z.Raw())
+		token := z.Token()
+		if skip != "" {
+			if tt == html.EndTagToken && token.Data == skip {
+				skip = ""
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			s.checkEntities(raw)
+			s.out.WriteString(textEscaper.Replace(token.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[token.Data] {
+				s.change("removed <%s> with its content", token.Data)
+				if tt == html.StartTagToken {
+					skip = token.Data
+				}
+				continue
+			}
+			if !s.allowed(token.Data) {
+				continue
+			}
+			s.writeStartTag(token)
+			if tt == html.SelfClosingTagToken || voidElements[token.Data] {
+				s.out.WriteString("/>")
+				continue
+			}
+			s.out.WriteString(">")
+			s.open = append(s.open, token.Data)
+		case html.EndTagToken:
+			if voidElements[token.Data] || !s.allowed(token.Data) {
+				continue
+			}
+			s.closeTo(token.Data)
+		case html.CommentToken:
+			s.change("removed comment")
+		case html.DoctypeToken:
+			s.change("removed doctype")
+		}
+	}
+
+	for len(s.open) > 0 {
+		s.closeLast()
+	}
+
+	body := s.out.String()
+	if strings.TrimSpace(body) == "" {
+		return "", s.changes
+	}
+	return "<body>" + body + "</body>", s.changes
+}
+
+func (s *sanitiser) allowed(name string) bool {
+	if wrapperElements[name] {
+		return false
+	}
+	if _, ok := BodyXMLElements[name]; !ok {
+		s.change("removed <%s>, keeping its content", name)
+		return false
+	}
+	return true
+}
+
+func (s *sanitiser) writeStartTag(token html.Token) {
+	allowedAttrs := BodyXMLElements[token.Data]
+	s.out.WriteString("<" + token.Data)
+	for _, a := range token.Attr {
+		if !contains(allowedAttrs, a.Key) {
+			s.change("removed attribute %s from <%s>", a.Key, token.Data)
+			continue
+		}
+		s.out.WriteString(" " + a.Key + `="` + attrEscaper.Replace(a.Val) + `"`)
+	}
+}
+
+// closeTo closes the innermost open element called name, closing the elements left open inside it.
+func (s *sanitiser) closeTo(name string) {
+	i := len(s.open) - 1
+	for i >= 0 && s.open[i] != name {
+		i--
+	}
+	if i < 0 {
+		s.change("removed unmatched </%s>", name)
+		return
+	}
+	for len(s.open) > i+1 {
+		s.closeLast()
+	}
+	s.out.WriteString("</" + name + ">")
+	s.open = s.open[:i]
+}
+
+func (s *sanitiser) closeLast() {
+	name := s.open[len(s.open)-1]
+	s.change("closed unclosed <%s>", name)
+	s.out.WriteString("</" + name + ">")
+	s.open = s.open[:len(s.open)-1]
+}
+
+func (s *sanitiser) checkEntities(raw string) {
+	for _, m := range entityRegexp.FindAllStringSubmatch(raw, -1) {
+		if !xmlEntities[m[1]] {
+			s.change("replaced HTML entity &%s;", m[1])
+		}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlCharacter drops the characters XML 1.0 does not allow, strings.Map having already turned
+// invalid UTF-8 into the replacement character.
+func xmlCharacter(r rune) rune {
+	switch {
+	case r == '\t', r == '\n', r == '\r',
+		r >= 0x20 && r <= 0xD7FF,
+		r >= 0xE000 && r <= 0xFFFD,
+		r >= 0x10000 && r <= 0x10FFFF:
+		return r
+	}
+	return -1
+}
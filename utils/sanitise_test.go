@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitiseTranscript(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		expected   string
+		changes    []string
+	}{
+		{
+			name:       "valid",
+			transcript: "<p>Here's the <strong>latest</strong> on markets.</p><p>A &amp; B</p>",
+			expected:   "<body><p>Here's the <strong>latest</strong> on markets.</p><p>A &amp; B</p></body>",
+		},
+		{
+			name:       "unclosed tags",
+			transcript: "<p>First<p>Second <em>emphasis</p>",
+			expected:   "<body><p>First<p>Second <em>emphasis</em></p></p></body>",
+			changes:    []string{"closed unclosed <em>", "closed unclosed <p>"},
+		},
+		{
+			name:       "unmatched closing tag",
+			transcript: "<p>Text</p></strong>",
+			expected:   "<body><p>Text</p></body>",
+			changes:    []string{"removed unmatched </strong>"},
+		},
+		{
+			name:       "HTML entities",
+			transcript: "<p>Caf&eacute;&nbsp;&lt;open&gt; &copy; A & B</p>",
+			expected:   "<body><p>Café &lt;open&gt; © A &amp; B</p></body>",
+			changes:    []string{"replaced HTML entity &eacute;", "replaced HTML entity &nbsp;", "replaced HTML entity &copy;"},
+		},
+		{
+			name:       "characters not allowed in XML",
+			transcript: "<p>Text\x10 and \xb7bytes</p>",
+			expected:   "<body><p>Text and \ufffdbytes</p></body>",
+			changes:    []string{"removed characters not allowed in XML"},
+		},
+		{
+			name:       "disallowed elements",
+			transcript: `<div class="x"><p style="color: red">Text <span>kept</span><script>alert(1)</script></p><!-- note --><a href="https://www.ft.com" target="_blank">link</a><br></div>`,
+			expected:   `<body><p>Text kept</p><a href="https://www.ft.com">link</a><br/></body>`,
+			changes: []string{
+				"removed <div>, keeping its content",
+				"removed attribute style from <p>",
+				"removed <span>, keeping its content",
+				"removed <script> with its content",
+				"removed comment",
+				"removed attribute target from <a>",
+			},
+		},
+		{
+			name:       "existing body",
+			transcript: "<html><body><p>Text</p></body></html>",
+			expected:   "<body><p>Text</p></body>",
+		},
+		{
+			name:       "no text",
+			transcript: "<script>alert(1)</script>",
+			expected:   "",
+			changes:    []string{"removed <script> with its content"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, changes := SanitiseTranscript(test.transcript)
+
+			assert.Equal(t, test.expected, body)
+			assert.Equal(t, test.changes, changes)
+			if body != "" {
+				assert.True(t, IsValidXHTML(body))
+			}
+		})
+	}
+}
@@ -3,35 +3,22 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
-	"io"
-	"strings"
 )
 
 func IsValidXHTML(data string) bool {
-	d := xml.NewDecoder(strings.NewReader(data))
-
-	valid := true
-	for {
-		_, err := d.Token()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			valid = false
-			break
-		}
-	}
-
-	return valid
+	return len(ValidateXHTML(data)) == 0
 }
 
+// UnsafeJSONMarshal marshals v like json.Marshal, but keeps < and > as they are so that markup stays readable.
 func UnsafeJSONMarshal(v interface{}) ([]byte, error) {
-	b, err := json.Marshal(v)
-	if err != nil {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
 		return nil, err
 	}
-	b = bytes.Replace(b, []byte("\\u003c"), []byte("<"), -1)
-	b = bytes.Replace(b, []byte("\\u003e"), []byte(">"), -1)
+	// & can only appear inside strings, where it is escaped like json.Marshal does
+	b := bytes.ReplaceAll(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("&"), []byte(`\u0026`))
 
 	return b, nil
 }
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// videoFixtures are the native videos of the mapper tests, used as seeds of the fuzz targets.
+var videoFixtures = []string{"../video/test-resources/video-input.json", "../video/testdata/golden/*.input.json"}
+
+func TestUnsafeJSONMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "markup",
+			value:    map[string]string{"transcript": "<p>Markets & bonds</p>"},
+			expected: `{"transcript":"<p>Markets \u0026 bonds</p>"}`,
+		},
+		{
+			name:     "escaped markup",
+			value:    `\u003cp\u003e`,
+			expected: `"\\u003cp\\u003e"`,
+		},
+		{
+			name:     "quotes and control characters",
+			value:    "\"<b>\"\n",
+			expected: `"\"<b>\"\n"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := UnsafeJSONMarshal(test.value)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+}
+
+func FuzzUnsafeJSONMarshal(f *testing.F) {
+	for _, seed := range []string{"<p>text</p>", `<`, `\\u003e`, "a & b", " ", "\xff"} {
+		f.Add(seed)
+	}
+	for _, transcript := range fixtureTranscripts(f) {
+		f.Add(transcript)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		b, err := UnsafeJSONMarshal(map[string]string{s: s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]string
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("invalid JSON %s: %v", b, err)
+		}
+
+		expected, _ := json.Marshal(s)
+		var value string
+		_ = json.Unmarshal(expected, &value)
+		if decoded[value] != value {
+			t.Fatalf("%q was marshalled as %s", s, b)
+		}
+	})
+}
+
+func FuzzIsValidXHTML(f *testing.F) {
+	for _, seed := range []string{"<body><p>Text</p></body>", "<p>First<em>line</p>", "<p>Caf&eacute;</p>", "<div><li>x</li></div>", "<", ""} {
+		f.Add(seed)
+	}
+	for _, transcript := range fixtureTranscripts(f) {
+		f.Add(transcript)
+	}
+
+	f.Fuzz(func(t *testing.T, data string) {
+		errs := ValidateXHTML(data, AllXHTMLErrors(), WithAllowedElements(BodyXMLElements), WithNestingRules(BodyXMLNesting))
+		for _, err := range errs {
+			if err.Line < 1 || err.Column < 1 {
+				t.Fatalf("invalid position in %+v", err)
+			}
+		}
+		if IsValidXHTML(data) != (len(ValidateXHTML(data, AllXHTMLErrors())) == 0) {
+			t.Fatalf("IsValidXHTML disagrees with ValidateXHTML for %q", data)
+		}
+
+		sanitised, _ := SanitiseTranscript(data)
+		if sanitised != "" && !IsValidXHTML(sanitised) {
+			t.Fatalf("sanitised %q into invalid XHTML %q", data, sanitised)
+		}
+	})
+}
+
+func fixtureTranscripts(f *testing.F) []string {
+	var transcripts []string
+	for _, pattern := range videoFixtures {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				f.Fatal(err)
+			}
+			var video struct {
+				Transcription struct {
+					Transcript string `json:"transcript"`
+				} `json:"transcription"`
+			}
+			if json.Unmarshal(data, &video) == nil && video.Transcription.Transcript != "" {
+				transcripts = append(transcripts, video.Transcription.Transcript)
+			}
+		}
+	}
+	return transcripts
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a WebVTT cue, with the markup removed from its text.
+type Cue struct {
+	ID    string
+	Start time.Duration
+	End   time.Duration
+	Voice string
+	Text  string
+}
+
+var (
+	cueTagRegexp   = regexp.MustCompile(`<[^>]*>`)
+	cueVoiceRegexp = regexp.MustCompile(`^<v(?:\.[^ \t>]+)*[ \t]+([^>]+)>`)
+)
+
+// ParseWebVTT returns the cues of a WebVTT document in the order they appear.
+// Notes, styles and regions are skipped.
+func ParseWebVTT(data string) ([]Cue, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	lines := strings.Split(data, "\n")
+	if !strings.HasPrefix(lines[0], "WEBVTT") || (len(lines[0]) > 6 && lines[0][6] != ' ' && lines[0][6] != '\t') {
+		return nil, fmt.Errorf("missing WEBVTT signature")
+	}
+
+	var cues []Cue
+	i := 1
+	// skip the header block
+	for i < len(lines) && lines[i] != "" {
+		i++
+	}
+	for i < len(lines) {
+		if lines[i] == "" {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(lines) && lines[i] != "" {
+			i++
+		}
+		block := lines[start:i]
+		if isWebVTTMetadata(block[0]) {
+			continue
+		}
+
+		cue := Cue{}
+		timing := 0
+		if !strings.Contains(block[0], "-->") {
+			cue.ID = block[0]
+			timing = 1
+		}
+		if timing >= len(block) {
+			return nil, fmt.Errorf("cue %q on line %d has no timing", cue.ID, start+1)
+		}
+
+		var err error
+		cue.Start, cue.End, err = parseCueTiming(block[timing])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cue timing on line %d: %w", start+timing+1, err)
+		}
+
+		text := strings.Join(block[timing+1:], " ")
+		if m := cueVoiceRegexp.FindStringSubmatch(text); m != nil {
+			cue.Voice = strings.TrimSpace(html.UnescapeString(m[1]))
+		}
+		cue.Text = strings.Join(strings.Fields(html.UnescapeString(cueTagRegexp.ReplaceAllString(text, ""))), " ")
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+func isWebVTTMetadata(line string) bool {
+	for _, keyword := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == keyword || strings.HasPrefix(line, keyword+" ") || strings.HasPrefix(line, keyword+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCueTiming(line string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("missing --> in %q", line)
+	}
+	start, err := parseCueTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	// the end timestamp may be followed by cue settings
+	endFields := strings.Fields(parts[1])
+	if len(endFields) == 0 {
+		return 0, 0, fmt.Errorf("missing end timestamp in %q", line)
+	}
+	end, err := parseCueTimestamp(endFields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("cue ends before it starts in %q", line)
+	}
+	return start, end, nil
+}
+
+// parseCueTimestamp parses timestamps in the [hh:]mm:ss.ttt form.
+func parseCueTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	secondsAndMillis := strings.SplitN(parts[len(parts)-1], ".", 2)
+	if len(secondsAndMillis) != 2 || len(secondsAndMillis[1]) != 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	var values []int
+	for _, p := range append(parts[:len(parts)-1], secondsAndMillis...) {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		values = append(values, n)
+	}
+
+	hours := 0
+	if len(values) == 4 {
+		hours, values = values[0], values[1:]
+	}
+	minutes, seconds, millis := values[0], values[1], values[2]
+	if minutes > 59 || seconds > 59 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(millis)*time.Millisecond, nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebVTT(t *testing.T) {
+	vtt := "\ufeffWEBVTT - Markets\r\nKind: captions\r\n\r\n" +
+		"NOTE edited by the video team\r\n\r\n" +
+		"STYLE\r\n::cue { color: yellow }\r\n\r\n" +
+		"intro\r\n00:00.000 --> 00:02.500 align:start\r\n<v.loud Katie Martin>From the FT in London,</v>\r\nhere's the latest\r\n\r\n" +
+		"01:00:03.000 --> 01:00:04.250\r\n<i>Markets</i> &amp; more\r\n"
+
+	cues, err := ParseWebVTT(vtt)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Cue{
+		{ID: "intro", Start: 0, End: 2500 * time.Millisecond, Voice: "Katie Martin", Text: "From the FT in London, here's the latest"},
+		{Start: time.Hour + 3*time.Second, End: time.Hour + 4250*time.Millisecond, Text: "Markets & more"},
+	}, cues)
+}
+
+func TestParseWebVTT_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing signature":  "00:00.000 --> 00:01.000\nText",
+		"invalid timestamp":  "WEBVTT\n\n00:00 --> 00:01.000\nText",
+		"invalid minutes":    "WEBVTT\n\n00:61.000 --> 01:01.000\nText",
+		"missing arrow":      "WEBVTT\n\nid\n00:00.000 00:01.000\nText",
+		"missing timing":     "WEBVTT\n\nid",
+		"ends before starts": "WEBVTT\n\n00:02.000 --> 00:01.000\nText",
+	}
+
+	for name, vtt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseWebVTT(vtt)
+			assert.Error(t, err)
+		})
+	}
+}
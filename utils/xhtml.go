@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const xhtmlTokenLength = 30
+
+// BodyXMLNesting lists the parents each bodyXML block element may have, the document root being "".
+var BodyXMLNesting = NestingRules{
+	"p":          {"", "body", "blockquote", "li"},
+	"h1":         {"", "body"},
+	"h2":         {"", "body"},
+	"h3":         {"", "body"},
+	"h4":         {"", "body"},
+	"h5":         {"", "body"},
+	"h6":         {"", "body"},
+	"ul":         {"", "body", "blockquote", "li"},
+	"ol":         {"", "body", "blockquote", "li"},
+	"li":         {"ul", "ol"},
+	"blockquote": {"", "body"},
+}
+
+// NestingRules maps an element name to the names of the elements it may be a direct child of.
+// Elements missing from the rules may appear anywhere.
+type NestingRules map[string][]string
+
+// XHTMLError locates a problem in an XHTML document, at the start of the token it was found in.
+type XHTMLError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+func (e XHTMLError) Error() string {
+	return fmt.Sprintf("%d:%d: %s near %q", e.Line, e.Column, e.Message, e.Token)
+}
+
+type xhtmlValidator struct {
+	allErrors bool
+	elements  map[string][]string
+	nesting   NestingRules
+}
+
+type XHTMLOption func(*xhtmlValidator)
+
+// AllXHTMLErrors keeps validating after the first error. Syntax errors always stop the validation.
+func AllXHTMLErrors() XHTMLOption {
+	return func(v *xhtmlValidator) {
+		v.allErrors = true
+	}
+}
+
+// WithAllowedElements reports the elements that are not keys of elements, such as BodyXMLElements.
+// The html and body wrappers are always allowed.
+func WithAllowedElements(elements map[string][]string) XHTMLOption {
+	return func(v *xhtmlValidator) {
+		v.elements = elements
+	}
+}
+
+// WithNestingRules reports the elements whose parent breaks rules.
+func WithNestingRules(rules NestingRules) XHTMLOption {
+	return func(v *xhtmlValidator) {
+		v.nesting = rules
+	}
+}
+
+// ValidateXHTML returns the first problem found in data, or all of them with AllXHTMLErrors.
+func ValidateXHTML(data string, opts ...XHTMLOption) []XHTMLError {
+	v := &xhtmlValidator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	var errs []XHTMLError
+	var open []string
+	d := xml.NewDecoder(strings.NewReader(data))
+	for {
+		line, column := d.InputPos()
+		offset := d.InputOffset()
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				err = errors.New(syntaxErr.Msg)
+			}
+			return append(errs, XHTMLError{
+				Line:    line,
+				Column:  column,
+				Token:   tokenAt(data, offset),
+				Message: err.Error(),
+			})
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if msg := v.check(name, open); msg != "" {
+				errs = append(errs, XHTMLError{
+					Line:    line,
+					Column:  column,
+					Token:   tokenAt(data, offset),
+					Message: msg,
+				})
+				if !v.allErrors {
+					return errs
+				}
+			}
+			open = append(open, name)
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+	return errs
+}
+
+func (v *xhtmlValidator) check(name string, open []string) string {
+	if v.elements != nil && !wrapperElements[name] {
+		if _, ok := v.elements[name]; !ok {
+			return fmt.Sprintf("element %s is not allowed", name)
+		}
+	}
+
+	parents, ok := v.nesting[name]
+	if !ok {
+		return ""
+	}
+	parent := ""
+	if len(open) > 0 {
+		parent = open[len(open)-1]
+	}
+	if !contains(parents, parent) {
+		if parent == "" {
+			return fmt.Sprintf("element %s is not allowed at the top level", name)
+		}
+		return fmt.Sprintf("element %s is not allowed inside %s", name, parent)
+	}
+	return ""
+}
+
+// tokenAt returns the start of the markup at offset, cut to a readable length.
+func tokenAt(data string, offset int64) string {
+	if offset < 0 || offset >= int64(len(data)) {
+		return ""
+	}
+	token := data[offset:]
+	if end := strings.IndexByte(token[1:], '<'); end >= 0 {
+		token = token[:end+1]
+	}
+	if runes := []rune(token); len(runes) > xhtmlTokenLength {
+		token = string(runes[:xhtmlTokenLength])
+	}
+	return token
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateXHTML(t *testing.T) {
+	bodyXML := []XHTMLOption{WithAllowedElements(BodyXMLElements), WithNestingRules(BodyXMLNesting)}
+
+	tests := []struct {
+		name     string
+		data     string
+		opts     []XHTMLOption
+		expected []XHTMLError
+	}{
+		{
+			name: "valid",
+			data: "<body><p>Text <strong>bold</strong></p><ul><li>item</li></ul></body>",
+			opts: append(bodyXML, AllXHTMLErrors()),
+		},
+		{
+			name:     "mismatched tag",
+			data:     "<p>First line\nsecond <em>line</p>",
+			expected: []XHTMLError{{Line: 2, Column: 16, Token: "</p>", Message: "element <em> closed by </p>"}},
+		},
+		{
+			name:     "HTML entity",
+			data:     "<p>Caf&eacute;</p>",
+			expected: []XHTMLError{{Line: 1, Column: 4, Token: "Caf&eacute;", Message: "invalid character entity &eacute;"}},
+		},
+		{
+			name: "first element error",
+			data: "<div><span>Text</span></div>",
+			opts: bodyXML,
+			expected: []XHTMLError{
+				{Line: 1, Column: 1, Token: "<div>", Message: "element div is not allowed"},
+			},
+		},
+		{
+			name: "all element errors",
+			data: "<div><span>Text</span></div>\n<p><li>item</li></p>",
+			opts: append(bodyXML, AllXHTMLErrors()),
+			expected: []XHTMLError{
+				{Line: 1, Column: 1, Token: "<div>", Message: "element div is not allowed"},
+				{Line: 1, Column: 6, Token: "<span>Text", Message: "element span is not allowed"},
+				{Line: 2, Column: 4, Token: "<li>item", Message: "element li is not allowed inside p"},
+			},
+		},
+		{
+			name:     "top level",
+			data:     "<li>item</li>",
+			opts:     bodyXML,
+			expected: []XHTMLError{{Line: 1, Column: 1, Token: "<li>item", Message: "element li is not allowed at the top level"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ValidateXHTML(test.data, test.opts...))
+		})
+	}
+}
+
+func TestIsValidXHTML(t *testing.T) {
+	assert.True(t, IsValidXHTML("<p>Text</p>"))
+	assert.False(t, IsValidXHTML("<p>Text"))
+}
@@ -0,0 +1,85 @@
+package video
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+var captionKinds = map[string]bool{
+	"subtitles":    true,
+	"captions":     true,
+	"descriptions": true,
+	"chapters":     true,
+}
+
+var captionMediaTypes = map[string]string{
+	"vtt":  "text/vtt",
+	"srt":  "application/x-subrip",
+	"ttml": "application/ttml+xml",
+	"dfxp": "application/ttml+xml",
+}
+
+func getCaptions(transcriptionMap map[string]interface{}) ([]caption, error) {
+	cList := []caption{}
+	if transcriptionMap == nil {
+		return cList, nil
+	}
+
+	captions, _ := transcriptionMap["captions"].([]interface{})
+	seen := map[string]bool{}
+	var problems []string
+	for i, elem := range captions {
+		captionMap, ok := elem.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("caption %d is not an object", i))
+			continue
+		}
+
+		url, _ := get("url", captionMap)
+		if url == "" {
+			if _, inline := captionMap["content"]; !inline {
+				problems = append(problems, fmt.Sprintf("caption %d has no url", i))
+			}
+			continue
+		}
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		c := caption{Url: url}
+		c.Format, _ = get("format", captionMap)
+		c.Format = strings.ToLower(c.Format)
+		c.Language, _ = get("language", captionMap)
+		c.Default, _ = getBool("default", captionMap)
+
+		kind, _ := get("kind", captionMap)
+		kind = strings.ToLower(kind)
+		if kind != "" && !captionKinds[kind] {
+			problems = append(problems, fmt.Sprintf("caption %d has an unknown kind %q", i, kind))
+			kind = ""
+		}
+		c.Kind = kind
+
+		c.MediaType, _ = get("mediaType", captionMap)
+		if c.MediaType == "" {
+			c.MediaType = captionMediaType(c.Format, url)
+		}
+		cList = append(cList, c)
+	}
+
+	if len(problems) > 0 {
+		return cList, newFieldError(warningMalformed, "Captions were skipped or changed: %v", strings.Join(problems, "; "))
+	}
+	return cList, nil
+}
+
+// captionMediaType infers the media type of a caption from its format, or else the extension of its URL.
+func captionMediaType(format string, url string) string {
+	if mediaType, ok := captionMediaTypes[format]; ok {
+		return mediaType
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(strings.SplitN(url, "?", 2)[0]), "."))
+	return captionMediaTypes[ext]
+}
@@ -0,0 +1,43 @@
+package video
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCaptions(t *testing.T) {
+	var transcription map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"captions": [
+			{"format": "vtt", "url": "https://next-video-editor.ft.com/783739.vtt", "mediaType": "text/vtt", "language": "en", "kind": "Captions", "default": true},
+			{"format": "vtt", "url": "https://next-video-editor.ft.com/783739.vtt", "mediaType": "text/vtt"},
+			{"url": "https://next-video-editor.ft.com/783739-fr.srt?v=2", "language": "fr", "kind": "subtitles"},
+			{"format": "ttml", "url": "https://next-video-editor.ft.com/783739-de", "language": "de", "kind": "karaoke"},
+			{"url": "https://next-video-editor.ft.com/783739-es"},
+			{"format": "vtt", "content": "WEBVTT"},
+			{"format": "vtt"},
+			"https://next-video-editor.ft.com/783739.vtt"
+		]
+	}`), &transcription))
+
+	captions, err := getCaptions(transcription)
+
+	assert.Equal(t, []caption{
+		{Url: "https://next-video-editor.ft.com/783739.vtt", MediaType: "text/vtt", Format: "vtt", Language: "en", Kind: "captions", Default: true},
+		{Url: "https://next-video-editor.ft.com/783739-fr.srt?v=2", MediaType: "application/x-subrip", Language: "fr", Kind: "subtitles"},
+		{Url: "https://next-video-editor.ft.com/783739-de", MediaType: "application/ttml+xml", Format: "ttml", Language: "de"},
+		{Url: "https://next-video-editor.ft.com/783739-es"},
+	}, captions)
+	assert.EqualError(t, err, `Captions were skipped or changed: caption 3 has an unknown kind "karaoke"; caption 6 has no url; caption 7 is not an object`)
+	assert.Equal(t, warningMalformed, warningCode(err))
+}
+
+func TestGetCaptions_NoTranscription(t *testing.T) {
+	captions, err := getCaptions(nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, captions)
+}
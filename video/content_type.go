@@ -0,0 +1,85 @@
+package video
+
+import "strings"
+
+const audioType = "Audio"
+
+// TypeMapping holds the UPP types emitted for videos and for audio-only content such as podcasts.
+type TypeMapping struct {
+	Video string
+	Audio string
+}
+
+// DefaultTypeMapping emits Video and Audio.
+var DefaultTypeMapping = TypeMapping{Video: videoType, Audio: audioType}
+
+// WithTypeMapping sets the UPP types emitted, the empty ones keeping their default.
+func WithTypeMapping(m TypeMapping) MapperOption {
+	return func(v *VideoMapper) {
+		if m.Video != "" {
+			v.types.Video = m.Video
+		}
+		if m.Audio != "" {
+			v.types.Audio = m.Audio
+		}
+	}
+}
+
+// contentType returns the UPP type of the content and its audio rendition when it is audio only.
+func (v VideoMapper) contentType(videoContent map[string]interface{}, dataSources []dataSource) (string, *audioRendition) {
+	types := v.types
+	if types.Video == "" {
+		types.Video = DefaultTypeMapping.Video
+	}
+	if types.Audio == "" {
+		types.Audio = DefaultTypeMapping.Audio
+	}
+
+	if !isAudioOnly(videoContent, dataSources) {
+		return types.Video, nil
+	}
+	return types.Audio, getAudioRendition(dataSources)
+}
+
+// isAudioOnly tells whether the native item is typed as audio, or all its renditions and streaming manifests are audio only.
+func isAudioOnly(videoContent map[string]interface{}, dataSources []dataSource) bool {
+	nativeType, _ := get("type", videoContent)
+	switch strings.ToLower(nativeType) {
+	case "audio", "podcast":
+		return true
+	}
+
+	for _, d := range dataSources {
+		if !d.AudioOnly {
+			return false
+		}
+	}
+	return len(dataSources) > 0
+}
+
+// getAudioRendition returns the audio rendition with the highest bitrate, or the first one when none has a bitrate.
+// Progressive renditions are preferred to streaming manifests.
+func getAudioRendition(dataSources []dataSource) *audioRendition {
+	var best *dataSource
+	for i, d := range dataSources {
+		if !d.AudioOnly {
+			continue
+		}
+		switch {
+		case best == nil, best.Streaming && !d.Streaming:
+			best = &dataSources[i]
+		case best.Streaming == d.Streaming && d.Bitrate != nil && (best.Bitrate == nil || *d.Bitrate > *best.Bitrate):
+			best = &dataSources[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &audioRendition{
+		BinaryUrl:  best.BinaryUrl,
+		MediaType:  best.MediaType,
+		Duration:   best.Duration,
+		AudioCodec: best.AudioCodec,
+		Bitrate:    best.Bitrate,
+	}
+}
@@ -0,0 +1,72 @@
+package video
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentType(t *testing.T) {
+	low, high := 64.0, 128.0
+	mp3 := dataSource{BinaryUrl: "http://ftvideo.example.com/low.mp3", MediaType: "audio/mpeg", AudioCodec: "mp3", AudioOnly: true, Bitrate: &low}
+	aac := dataSource{BinaryUrl: "http://ftvideo.example.com/high.m4a", MediaType: "audio/mp4", AudioCodec: "aac", AudioOnly: true, Bitrate: &high}
+	mp4 := dataSource{BinaryUrl: "http://ftvideo.example.com/640x360.mp4", MediaType: "video/mp4", VideoCodec: "h264"}
+	hls := dataSource{BinaryUrl: "http://ftvideo.example.com/master.m3u8", MediaType: mediaTypeHLS, AudioCodec: "aac", Streaming: true}
+	hlsAudio := dataSource{BinaryUrl: "http://ftvideo.example.com/audio.m3u8", MediaType: mediaTypeHLS, AudioCodec: "aac", Streaming: true, AudioOnly: true, Bitrate: &high}
+
+	tests := []struct {
+		name         string
+		videoContent map[string]interface{}
+		dataSources  []dataSource
+		expectedType string
+		expectedURL  string
+	}{
+		{name: "video", dataSources: []dataSource{mp3, mp4}, expectedType: videoType},
+		{name: "no renditions", dataSources: []dataSource{hls}, expectedType: videoType},
+		{name: "audio renditions", dataSources: []dataSource{hlsAudio, mp3, aac}, expectedType: audioType, expectedURL: aac.BinaryUrl},
+		{name: "streaming with an audio fallback", dataSources: []dataSource{hls, mp3}, expectedType: videoType},
+		{name: "audio streaming only", dataSources: []dataSource{hlsAudio}, expectedType: audioType, expectedURL: hlsAudio.BinaryUrl},
+		{name: "progressive audio preferred", dataSources: []dataSource{hlsAudio, mp3}, expectedType: audioType, expectedURL: mp3.BinaryUrl},
+		{name: "native audio type", videoContent: map[string]interface{}{"type": "Podcast"}, expectedType: audioType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contentType, audio := VideoMapper{}.contentType(test.videoContent, test.dataSources)
+
+			assert.Equal(t, test.expectedType, contentType)
+			if test.expectedURL == "" {
+				assert.Nil(t, audio)
+			} else if assert.NotNil(t, audio) {
+				assert.Equal(t, test.expectedURL, audio.BinaryUrl)
+			}
+		})
+	}
+}
+
+func TestTransformMsg_AudioTypeMapping(t *testing.T) {
+	message := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{
+			"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+			"encoding": {"outputs": [{"audioCodec": "mp3", "duration": 68544, "mediaType": "audio/mpeg", "url": "http://ftvideo.example.com/0x0.mp3"}]}
+		}`,
+	}
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithTypeMapping(TypeMapping{Audio: "MediaResource"}))
+
+	msg, _, _, err := m.TransformMsg(message)
+
+	require.NoError(t, err)
+	var event publicationEvent
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	assert.Equal(t, "MediaResource", event.Payload.Type)
+	duration := 68544.0
+	assert.Equal(t, &audioRendition{BinaryUrl: "http://ftvideo.example.com/0x0.mp3", MediaType: "audio/mpeg", Duration: &duration, AudioCodec: "mp3"}, event.Payload.Audio)
+}
@@ -0,0 +1,260 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaTypeHLS  = "application/x-mpegURL"
+	mediaTypeDASH = "application/dash+xml"
+
+	orientationLandscape = "landscape"
+	orientationPortrait  = "portrait"
+	orientationSquare    = "square"
+)
+
+// getDataSources maps the encoding outputs to data sources, the streaming manifests first and
+// the renditions sorted by resolution with the audio ones first. Image outputs are returned
+// apart as poster images, sorted by resolution too. Outputs that are not valid, or repeat the
+// URL of an earlier one, are skipped and reported in the returned error, as are the optional
+// fields dropped for being out of range.
+func getDataSources(encoding interface{}) ([]dataSource, []posterImage, error) {
+	encodingMap, ok := encoding.(map[string]interface{})
+	if !ok {
+		return nil, nil, newFieldError(warningMissing, "Encodings field of video JSON is null, dataSource will be empty.")
+	}
+
+	outputs, ok := encodingMap["outputs"]
+	if !ok {
+		return nil, nil, newFieldError(warningMissing, "Outputs field of video JSON is null, dataSource will be empty.")
+	}
+
+	outputsArray, ok := outputs.([]interface{})
+	if !ok {
+		return nil, nil, newFieldError(warningWrongType, "Outputs field of video JSON is not an array, dataSource will be empty.")
+	}
+
+	dataSourcesList := []dataSource{}
+	var posters []posterImage
+	seen := map[string]bool{}
+	var problems []string
+	for i, elem := range outputsArray {
+		d, dropped, err := getDataSource(elem)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("output %d %v", i, err))
+			continue
+		}
+		for _, field := range dropped {
+			problems = append(problems, fmt.Sprintf("output %d %v", i, field))
+		}
+		if seen[d.BinaryUrl] {
+			problems = append(problems, fmt.Sprintf("output %d repeats the url %v", i, d.BinaryUrl))
+			continue
+		}
+		seen[d.BinaryUrl] = true
+
+		if mediaType, ok := imageMediaType(d.MediaType, d.BinaryUrl); ok {
+			posters = append(posters, posterImage{
+				Url:         d.BinaryUrl,
+				MediaType:   mediaType,
+				PixelWidth:  d.PixelWidth,
+				PixelHeight: d.PixelHeight,
+			})
+			continue
+		}
+		dataSourcesList = append(dataSourcesList, d)
+	}
+
+	sort.SliceStable(dataSourcesList, func(i, j int) bool {
+		if dataSourcesList[i].Streaming != dataSourcesList[j].Streaming {
+			return dataSourcesList[i].Streaming
+		}
+		return resolution(dataSourcesList[i].PixelWidth, dataSourcesList[i].PixelHeight) <
+			resolution(dataSourcesList[j].PixelWidth, dataSourcesList[j].PixelHeight)
+	})
+	sort.SliceStable(posters, func(i, j int) bool {
+		return resolution(posters[i].PixelWidth, posters[i].PixelHeight) < resolution(posters[j].PixelWidth, posters[j].PixelHeight)
+	})
+
+	if len(problems) > 0 {
+		return dataSourcesList, posters, newFieldError(warningMalformed, "Skipped encoding outputs: %v", strings.Join(problems, "; "))
+	}
+	return dataSourcesList, posters, nil
+}
+
+// bestPoster returns the URL of the poster image with the highest resolution.
+func bestPoster(posters []posterImage) (string, bool) {
+	if len(posters) == 0 {
+		return "", false
+	}
+	return posters[len(posters)-1].Url, true
+}
+
+// numberRange bounds the values accepted for a numeric field of the encoding outputs.
+type numberRange struct {
+	min float64
+	max float64
+}
+
+// contains is false for NaN, which no comparison holds for.
+func (r numberRange) contains(n float64) bool {
+	return n >= r.min && n <= r.max
+}
+
+var (
+	pixelRange     = numberRange{0, 16384}
+	durationRange  = numberRange{0, 24 * 60 * 60 * 1000}
+	bitrateRange   = numberRange{1, 1000000}
+	fileSizeRange  = numberRange{1, 1 << 40}
+	frameRateRange = numberRange{1, 240}
+)
+
+// getDataSource maps an encoding output. The returned error means the output is not a valid
+// rendition, while the optional fields out of range are dropped and described in the returned list.
+func getDataSource(elem interface{}) (dataSource, []string, error) {
+	elemMap, ok := elem.(map[string]interface{})
+	if !ok {
+		return dataSource{}, nil, fmt.Errorf("is not an object")
+	}
+
+	binaryUrl, err := get("url", elemMap)
+	if err != nil || binaryUrl == "" {
+		return dataSource{}, nil, fmt.Errorf("has no url")
+	}
+
+	pWidth, err := getOptionalNumber("width", elemMap, pixelRange)
+	if err != nil {
+		return dataSource{}, nil, err
+	}
+	pHeight, err := getOptionalNumber("height", elemMap, pixelRange)
+	if err != nil {
+		return dataSource{}, nil, err
+	}
+	duration, err := getOptionalNumber("duration", elemMap, durationRange)
+	if err != nil {
+		return dataSource{}, nil, err
+	}
+	mediaType, _ := get("mediaType", elemMap)
+	videoCodec, _ := get("videoCodec", elemMap)
+	audioCodec, _ := get("audioCodec", elemMap)
+	container, _ := get("container", elemMap)
+
+	var dropped []string
+	bitrate, err := getOptionalNumber("bitrate", elemMap, bitrateRange)
+	if err != nil {
+		dropped = append(dropped, err.Error())
+	}
+	fileSize, err := getOptionalNumber("fileSize", elemMap, fileSizeRange)
+	if err != nil {
+		dropped = append(dropped, err.Error())
+	}
+	frameRate, err := getOptionalNumber("frameRate", elemMap, frameRateRange)
+	if err != nil {
+		dropped = append(dropped, err.Error())
+	}
+
+	d := dataSource{
+		BinaryUrl:   binaryUrl,
+		PixelWidth:  pWidth,
+		PixelHeight: pHeight,
+		MediaType:   mediaType,
+		Duration:    duration,
+		VideoCodec:  videoCodec,
+		AudioCodec:  audioCodec,
+		Bitrate:     bitrate,
+		FileSize:    fileSize,
+		FrameRate:   frameRate,
+		Container:   strings.ToLower(container),
+	}
+	deriveRenditionFields(&d)
+	return d, dropped, nil
+}
+
+// getOptionalNumber returns nil when the field is missing, and an error when it is not a number within r.
+func getOptionalNumber(key string, inputMap map[string]interface{}, r numberRange) (*float64, error) {
+	if _, ok := inputMap[key]; !ok {
+		return nil, nil
+	}
+	value, err := getNumber(key, inputMap)
+	if err != nil {
+		return nil, fmt.Errorf("has a %s that is not a number", key)
+	}
+	if !r.contains(*value) {
+		return nil, fmt.Errorf("has a %s of %s out of the %s to %s range", key, formatNumber(*value), formatNumber(r.min), formatNumber(r.max))
+	}
+	return value, nil
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// streamingMediaType returns the media type of the adaptive streaming manifests, recognised
+// by their media type or else the extension of their URL.
+func streamingMediaType(mediaType string, url string) (string, bool) {
+	switch strings.ToLower(mediaType) {
+	case "application/x-mpegurl", "application/vnd.apple.mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		return mediaTypeHLS, true
+	case mediaTypeDASH:
+		return mediaTypeDASH, true
+	case "":
+		switch strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0])) {
+		case ".m3u8":
+			return mediaTypeHLS, true
+		case ".mpd":
+			return mediaTypeDASH, true
+		}
+	}
+	return mediaType, false
+}
+
+// imageMediaType returns the media type of the image outputs, recognised by their media type
+// or else the extension of their URL.
+func imageMediaType(mediaType string, url string) (string, bool) {
+	if mediaType != "" {
+		return mediaType, strings.HasPrefix(strings.ToLower(mediaType), "image/")
+	}
+	switch strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0])) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg", true
+	case ".png":
+		return "image/png", true
+	case ".webp":
+		return "image/webp", true
+	}
+	return "", false
+}
+
+func deriveRenditionFields(d *dataSource) {
+	audioMediaType := strings.HasPrefix(strings.ToLower(d.MediaType), "audio/")
+	d.MediaType, d.Streaming = streamingMediaType(d.MediaType, d.BinaryUrl)
+
+	if d.PixelWidth != nil && d.PixelHeight != nil && *d.PixelWidth > 0 && *d.PixelHeight > 0 {
+		ratio := math.Round(*d.PixelWidth / *d.PixelHeight * 1000) / 1000
+		d.AspectRatio = &ratio
+		switch {
+		case *d.PixelWidth > *d.PixelHeight:
+			d.Orientation = orientationLandscape
+		case *d.PixelWidth < *d.PixelHeight:
+			d.Orientation = orientationPortrait
+		default:
+			d.Orientation = orientationSquare
+		}
+	}
+
+	// a manifest may list video renditions whatever its own fields say, so only its media type tells it is audio only
+	d.AudioOnly = audioMediaType ||
+		(!d.Streaming && d.VideoCodec == "" && d.PixelWidth == nil && d.PixelHeight == nil && d.AudioCodec != "")
+}
+
+func resolution(width *float64, height *float64) float64 {
+	if width == nil || height == nil {
+		return 0
+	}
+	return *width * *height
+}
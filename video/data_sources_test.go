@@ -0,0 +1,150 @@
+package video
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDataSources(t *testing.T) {
+	var encoding map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"outputs": [
+			{"videoCodec": "h264", "mediaType": "video/mp4", "width": 1280, "height": 720, "url": "http://ftvideo.example.com/1280x720.mp4"},
+			{"videoCodec": "h264", "mediaType": "video/mp4", "width": 360, "height": 640, "url": "http://ftvideo.example.com/360x640.mp4"},
+			{"audioCodec": "mp3", "mediaType": "audio/mpeg", "duration": 68544, "url": "http://ftvideo.example.com/0x0.mp3"},
+			{"videoCodec": "h264", "mediaType": "video/mp4", "width": 1280, "height": 720, "url": "http://ftvideo.example.com/1280x720.mp4"},
+			{"videoCodec": "h264", "mediaType": "video/mp4", "width": 480, "height": 480, "url": "http://ftvideo.example.com/480x480.mp4"},
+			{"videoCodec": "h264", "mediaType": "video/mp4", "width": "wide", "height": 480, "url": "http://ftvideo.example.com/wide.mp4"},
+			{"videoCodec": "h264", "mediaType": "video/mp4"},
+			"http://ftvideo.example.com/bad.mp4"
+		]
+	}`), &encoding))
+
+	dataSources, _, err := getDataSources(encoding)
+
+	assert.EqualError(t, err, "Skipped encoding outputs: output 3 repeats the url http://ftvideo.example.com/1280x720.mp4; "+
+		"output 5 has a width that is not a number; output 6 has no url; output 7 is not an object")
+	assert.Equal(t, warningMalformed, warningCode(err))
+	require.Len(t, dataSources, 4)
+
+	assert.Equal(t, "http://ftvideo.example.com/0x0.mp3", dataSources[0].BinaryUrl)
+	assert.True(t, dataSources[0].AudioOnly)
+	assert.Nil(t, dataSources[0].AspectRatio)
+	assert.Empty(t, dataSources[0].Orientation)
+
+	assert.Equal(t, "http://ftvideo.example.com/360x640.mp4", dataSources[1].BinaryUrl)
+	assert.Equal(t, 0.563, *dataSources[1].AspectRatio)
+	assert.Equal(t, orientationPortrait, dataSources[1].Orientation)
+	assert.False(t, dataSources[1].AudioOnly)
+
+	assert.Equal(t, "http://ftvideo.example.com/480x480.mp4", dataSources[2].BinaryUrl)
+	assert.Equal(t, 1.0, *dataSources[2].AspectRatio)
+	assert.Equal(t, orientationSquare, dataSources[2].Orientation)
+
+	assert.Equal(t, "http://ftvideo.example.com/1280x720.mp4", dataSources[3].BinaryUrl)
+	assert.Equal(t, 1.778, *dataSources[3].AspectRatio)
+	assert.Equal(t, orientationLandscape, dataSources[3].Orientation)
+}
+
+func TestGetDataSources_OutputsNotAnArray(t *testing.T) {
+	dataSources, _, err := getDataSources(map[string]interface{}{"outputs": "not an array"})
+
+	assert.Empty(t, dataSources)
+	assert.EqualError(t, err, "Outputs field of video JSON is not an array, dataSource will be empty.")
+	assert.Equal(t, warningWrongType, warningCode(err))
+}
+
+func TestGetDataSources_RenditionMetadata(t *testing.T) {
+	var encoding map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"outputs": [
+			{"mediaType": "video/mp4", "width": 1280, "height": 720, "bitrate": 2500, "fileSize": 21474836, "frameRate": 29.97, "container": "MP4", "url": "http://ftvideo.example.com/1280x720.mp4"},
+			{"mediaType": "video/mp4", "width": 640, "height": 360, "bitrate": -1, "fileSize": "big", "frameRate": 1000, "url": "http://ftvideo.example.com/640x360.mp4"},
+			{"mediaType": "video/mp4", "width": 100000, "height": 360, "url": "http://ftvideo.example.com/huge.mp4"}
+		]
+	}`), &encoding))
+
+	dataSources, _, err := getDataSources(encoding)
+
+	assert.EqualError(t, err, "Skipped encoding outputs: "+
+		"output 1 has a bitrate of -1 out of the 1 to 1000000 range; "+
+		"output 1 has a fileSize that is not a number; "+
+		"output 1 has a frameRate of 1000 out of the 1 to 240 range; "+
+		"output 2 has a width of 100000 out of the 0 to 16384 range")
+	require.Len(t, dataSources, 2)
+
+	assert.Nil(t, dataSources[0].Bitrate)
+	assert.Nil(t, dataSources[0].FileSize)
+	assert.Nil(t, dataSources[0].FrameRate)
+	assert.Empty(t, dataSources[0].Container)
+
+	assert.Equal(t, 2500.0, *dataSources[1].Bitrate)
+	assert.Equal(t, 21474836.0, *dataSources[1].FileSize)
+	assert.Equal(t, 29.97, *dataSources[1].FrameRate)
+	assert.Equal(t, "mp4", dataSources[1].Container)
+}
+
+func TestGetDataSources_StreamingManifests(t *testing.T) {
+	var encoding map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"outputs": [
+			{"mediaType": "audio/mpeg", "audioCodec": "mp3", "url": "http://ftvideo.example.com/0x0.mp3"},
+			{"mediaType": "video/mp4", "width": 640, "height": 360, "url": "http://ftvideo.example.com/640x360.mp4"},
+			{"mediaType": "application/dash+xml", "url": "http://ftvideo.example.com/manifest.mpd"},
+			{"mediaType": "application/vnd.apple.mpegurl", "audioCodec": "aac", "url": "http://ftvideo.example.com/master.m3u8"},
+			{"url": "http://ftvideo.example.com/other.m3u8?token=1"},
+			{"mediaType": "audio/x-mpegURL", "url": "http://ftvideo.example.com/audio.m3u8"}
+		]
+	}`), &encoding))
+
+	dataSources, _, err := getDataSources(encoding)
+
+	require.NoError(t, err)
+	var urls, mediaTypes []string
+	for _, d := range dataSources {
+		urls = append(urls, d.BinaryUrl)
+		mediaTypes = append(mediaTypes, d.MediaType)
+		assert.Equal(t, d.MediaType == mediaTypeHLS || d.MediaType == mediaTypeDASH, d.Streaming, d.BinaryUrl)
+	}
+	assert.Equal(t, []string{
+		"http://ftvideo.example.com/manifest.mpd",
+		"http://ftvideo.example.com/master.m3u8",
+		"http://ftvideo.example.com/other.m3u8?token=1",
+		"http://ftvideo.example.com/audio.m3u8",
+		"http://ftvideo.example.com/0x0.mp3",
+		"http://ftvideo.example.com/640x360.mp4",
+	}, urls)
+	assert.Equal(t, []string{mediaTypeDASH, mediaTypeHLS, mediaTypeHLS, mediaTypeHLS, "audio/mpeg", "video/mp4"}, mediaTypes)
+	assert.False(t, dataSources[1].AudioOnly, "A manifest should only be audio only when its media type says so")
+	assert.True(t, dataSources[3].AudioOnly)
+}
+
+func TestGetDataSources_PosterImages(t *testing.T) {
+	var encoding map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"outputs": [
+			{"mediaType": "video/mp4", "width": 640, "height": 360, "url": "http://ftvideo.example.com/640x360.mp4"},
+			{"mediaType": "image/jpeg", "width": 1280, "height": 720, "url": "http://ftvideo.example.com/poster.jpg"},
+			{"width": 160, "height": 90, "url": "http://ftvideo.example.com/thumbnail.png"}
+		]
+	}`), &encoding))
+
+	dataSources, posters, err := getDataSources(encoding)
+
+	require.NoError(t, err)
+	require.Len(t, dataSources, 1)
+	assert.Equal(t, "http://ftvideo.example.com/640x360.mp4", dataSources[0].BinaryUrl)
+	width, height := 160.0, 90.0
+	bestWidth, bestHeight := 1280.0, 720.0
+	assert.Equal(t, []posterImage{
+		{Url: "http://ftvideo.example.com/thumbnail.png", MediaType: "image/png", PixelWidth: &width, PixelHeight: &height},
+		{Url: "http://ftvideo.example.com/poster.jpg", MediaType: "image/jpeg", PixelWidth: &bestWidth, PixelHeight: &bestHeight},
+	}, posters)
+
+	poster, ok := bestPoster(posters)
+	assert.True(t, ok)
+	assert.Equal(t, "http://ftvideo.example.com/poster.jpg", poster)
+}
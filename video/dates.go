@@ -0,0 +1,130 @@
+package video
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DateValidationWarn = "warn"
+	DateValidationFail = "fail"
+
+	defaultMaxFutureSkew = 5 * time.Minute
+)
+
+// acceptedDateFormats are tried in order when parsing the dates of the native video.
+// Dates without a time zone are taken as UTC.
+var acceptedDateFormats = []string{
+	time.RFC3339Nano,
+	dateFormat,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// DateValidation sets what happens to dates that cannot be parsed, are further in the future than
+// MaxFutureSkew, or have the first publication after the publication. Mode is either
+// DateValidationWarn, which raises warnings and drops the unparseable dates, or DateValidationFail,
+// which fails the mapping.
+type DateValidation struct {
+	Mode          string
+	MaxFutureSkew time.Duration
+}
+
+// WithDateValidation sets how the dates of the native video are validated.
+func WithDateValidation(d DateValidation) MapperOption {
+	return func(v *VideoMapper) {
+		v.dateValidation = d
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range acceptedDateFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date format: %q", value)
+}
+
+func (v VideoMapper) maxFutureSkew() time.Duration {
+	if v.dateValidation.MaxFutureSkew <= 0 {
+		return defaultMaxFutureSkew
+	}
+	return v.dateValidation.MaxFutureSkew
+}
+
+// normaliseDate parses value and formats it in UTC with dateFormat. Unparseable dates are
+// returned empty, along with the problems found.
+func (v VideoMapper) normaliseDate(field string, value string) (string, time.Time, []string) {
+	t, err := parseDate(value)
+	if err != nil {
+		return "", time.Time{}, []string{fmt.Sprintf("%s: %v", field, err)}
+	}
+
+	var problems []string
+	if t.After(v.now().Add(v.maxFutureSkew())) {
+		problems = append(problems, fmt.Sprintf("%s: %v is in the future", field, value))
+	}
+	return t.UTC().Format(dateFormat), t, problems
+}
+
+// checkDates normalises the dates of the payload, raising a warning for every problem found,
+// or failing the mapping with the DateValidationFail mode.
+func (v VideoMapper) checkDates(p *videoPayload, report *mappingReport) error {
+	var problems []string
+	var firstPublished, published time.Time
+
+	if p.FirstPublishedDate != "" {
+		var dateProblems []string
+		p.FirstPublishedDate, firstPublished, dateProblems = v.normaliseDate("firstPublishedDate", p.FirstPublishedDate)
+		problems = append(problems, v.dateProblems(report, "firstPublishedDate", dateProblems)...)
+	}
+	if p.PublishedDate != "" {
+		var dateProblems []string
+		p.PublishedDate, published, dateProblems = v.normaliseDate("publishedDate", p.PublishedDate)
+		problems = append(problems, v.dateProblems(report, "publishedDate", dateProblems)...)
+	}
+	if !firstPublished.IsZero() && !published.IsZero() && firstPublished.After(published) {
+		problems = append(problems, v.dateProblems(report, "firstPublishedDate", []string{
+			fmt.Sprintf("firstPublishedDate: %v is after the publishedDate %v", p.FirstPublishedDate, p.PublishedDate),
+		})...)
+	}
+
+	if len(problems) > 0 && v.dateValidation.Mode == DateValidationFail {
+		return &mappingError{errorClassInvalidDate, fmt.Errorf("invalid dates: %v", strings.Join(problems, "; "))}
+	}
+	return nil
+}
+
+// checkLastModified normalises the Message-Timestamp header like the dates of the payload.
+// With the DateValidationWarn mode, an unparseable header is replaced by the current time.
+func (v VideoMapper) checkLastModified(lastModified string, report *mappingReport) (string, error) {
+	normalised, _, problems := v.normaliseDate("lastModified", lastModified)
+	v.dateProblems(report, "lastModified", problems)
+	if len(problems) > 0 && v.dateValidation.Mode == DateValidationFail {
+		return "", &mappingError{errorClassInvalidDate, fmt.Errorf("invalid dates: %v", strings.Join(problems, "; "))}
+	}
+	if normalised == "" {
+		report.record("lastModified", "", ruleNow, true)
+		return v.now().UTC().Format(dateFormat), nil
+	}
+	report.record("lastModified", "header:Message-Timestamp", ruleDate, false)
+	return normalised, nil
+}
+
+func (v VideoMapper) dateProblems(report *mappingReport, field string, problems []string) []string {
+	if v.dateValidation.Mode == DateValidationFail {
+		return problems
+	}
+	for _, problem := range problems {
+		v.warn(report, field, newFieldError(warningInvalidDate, "%v", problem), "%v", problem)
+	}
+	return problems
+}
@@ -0,0 +1,107 @@
+package video
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(2017, 4, 6, 9, 58, 35, 440000000, time.UTC)
+	for _, value := range []string{
+		"2017-04-06T09:58:35.440Z",
+		"2017-04-06T10:58:35.44+01:00",
+		"2017-04-06T10:58:35.440+0100",
+		"2017-04-06T09:58:35.440",
+		"2017-04-06 09:58:35.44",
+	} {
+		parsed, err := parseDate(value)
+		if assert.NoError(t, err, value) {
+			assert.True(t, expected.Equal(parsed), value)
+		}
+	}
+
+	parsed, err := parseDate("Thu, 06 Apr 2017 10:58:35 +0100")
+	assert.NoError(t, err)
+	assert.Equal(t, "2017-04-06T09:58:35.000Z", parsed.UTC().Format(dateFormat))
+
+	_, err = parseDate("06/04/2017")
+	assert.EqualError(t, err, `unrecognised date format: "06/04/2017"`)
+}
+
+func dateTestMessage(timestamp string, firstPublishedAt string, publishedAt string) kafka.FTMessage {
+	return kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": timestamp,
+		},
+		Body: `{
+			"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+			"firstPublishedAt": "` + firstPublishedAt + `",
+			"publishedAt": "` + publishedAt + `"
+		}`,
+	}
+}
+
+func TestTransformMsg_NormalisedDates(t *testing.T) {
+	msg, _, warnings, err := mapper.TransformMsg(dateTestMessage("2017-04-13T11:27:32.353+01:00", "2017-04-06 09:58:35", "Wed, 12 Apr 2017 13:29:48 +0100"))
+
+	require.NoError(t, err)
+	var event publicationEvent
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	assert.Equal(t, "2017-04-13T10:27:32.353Z", event.LastModified)
+	assert.Equal(t, "2017-04-13T10:27:32.353Z", msg.Headers["Message-Timestamp"])
+	assert.Equal(t, "2017-04-06T09:58:35.000Z", event.Payload.FirstPublishedDate)
+	assert.Equal(t, "2017-04-12T12:29:48.000Z", event.Payload.PublishedDate)
+	for _, w := range warnings {
+		assert.NotEqual(t, warningInvalidDate, w.Code)
+	}
+}
+
+func TestTransformMsg_InvalidDatesWarn(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(dateFormat)
+
+	msg, _, warnings, err := mapper.TransformMsg(dateTestMessage("not a date", future, "2017-04-12T12:29:48.331Z"))
+
+	require.NoError(t, err)
+	var event publicationEvent
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	_, err = time.Parse(dateFormat, event.LastModified)
+	assert.NoError(t, err, "An unparseable Message-Timestamp should be replaced by the current time")
+	assert.Equal(t, future, event.Payload.FirstPublishedDate)
+	assert.Contains(t, warnings, MappingWarning{Field: "lastModified", Code: warningInvalidDate, Message: `lastModified: unrecognised date format: "not a date"`})
+	assert.Contains(t, warnings, MappingWarning{Field: "firstPublishedDate", Code: warningInvalidDate, Message: "firstPublishedDate: " + future + " is in the future"})
+	assert.Contains(t, warnings, MappingWarning{Field: "firstPublishedDate", Code: warningInvalidDate, Message: "firstPublishedDate: " + future + " is after the publishedDate 2017-04-12T12:29:48.331Z"})
+}
+
+func TestExplainMsg_InvalidMessageTimestamp(t *testing.T) {
+	clock := func() time.Time { return time.Date(2017, 4, 13, 10, 27, 32, 353000000, time.UTC) }
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithClock(clock))
+
+	explanation, err := m.ExplainMsg(context.Background(), dateTestMessage("not a date", "2017-04-06T09:58:35.440Z", "2017-04-12T12:29:48.331Z"))
+
+	require.NoError(t, err)
+	assert.Contains(t, string(explanation.Message), `"lastModified":"2017-04-13T10:27:32.353Z"`)
+	assert.Contains(t, explanation.Fields, FieldProvenance{Field: "lastModified", Rule: ruleNow, Defaulted: true},
+		"An unparseable Message-Timestamp should be explained as replaced by the current time")
+	assert.NotContains(t, explanation.Fields, FieldProvenance{Field: "lastModified", Source: "header:Message-Timestamp", Rule: ruleDate})
+}
+
+func TestTransformMsg_InvalidDatesFail(t *testing.T) {
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithDateValidation(DateValidation{Mode: DateValidationFail}))
+
+	_, _, _, err := m.TransformMsg(dateTestMessage(messageTimestamp, "2017-04-13T12:29:48.331Z", "2017-04-12T12:29:48.331Z"))
+
+	assert.EqualError(t, err, "invalid dates: firstPublishedDate: 2017-04-13T12:29:48.331Z is after the publishedDate 2017-04-12T12:29:48.331Z")
+	assert.Equal(t, errorClassInvalidDate, errorClass(err))
+
+	_, _, _, err = m.TransformMsg(dateTestMessage("13/04/2017", "2017-04-06T09:58:35.440Z", "2017-04-12T12:29:48.331Z"))
+
+	assert.Equal(t, errorClassInvalidDate, errorClass(err))
+}
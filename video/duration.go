@@ -0,0 +1,80 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDurationTolerance = time.Second
+
+// primaryRendition returns the rendition the duration of the content is taken from: the video
+// rendition with the highest resolution or, for audio-only content, the first audio rendition.
+func primaryRendition(dataSources []dataSource) *dataSource {
+	var primary *dataSource
+	for i, d := range dataSources {
+		if d.Streaming || d.Duration == nil {
+			continue
+		}
+		switch {
+		case primary == nil:
+			primary = &dataSources[i]
+		case primary.AudioOnly && !d.AudioOnly:
+			primary = &dataSources[i]
+		case primary.AudioOnly == d.AudioOnly && resolution(d.PixelWidth, d.PixelHeight) > resolution(primary.PixelWidth, primary.PixelHeight):
+			primary = &dataSources[i]
+		}
+	}
+	return primary
+}
+
+// getDuration returns the duration of the primary rendition in milliseconds, and an error
+// listing the renditions whose duration differs from it by more than tolerance.
+func getDuration(dataSources []dataSource, tolerance time.Duration) (*float64, error) {
+	primary := primaryRendition(dataSources)
+	if primary == nil {
+		return nil, nil
+	}
+
+	var inconsistent []string
+	for _, d := range dataSources {
+		if d.Streaming || d.Duration == nil || d.BinaryUrl == primary.BinaryUrl {
+			continue
+		}
+		if math.Abs(*d.Duration-*primary.Duration) > float64(tolerance.Milliseconds()) {
+			inconsistent = append(inconsistent, fmt.Sprintf("%v lasts %s ms", d.BinaryUrl, formatNumber(*d.Duration)))
+		}
+	}
+
+	duration := *primary.Duration
+	if len(inconsistent) > 0 {
+		return &duration, newFieldError(warningInconsistent, "Renditions differ from the %s ms of %v by more than %v: %v",
+			formatNumber(duration), primary.BinaryUrl, tolerance, strings.Join(inconsistent, "; "))
+	}
+	return &duration, nil
+}
+
+// isoDuration formats a duration in milliseconds as an ISO-8601 duration, such as PT1M8.587S.
+func isoDuration(millis float64) string {
+	total := int64(math.Round(millis))
+	hours := total / int64(time.Hour/time.Millisecond)
+	total -= hours * int64(time.Hour/time.Millisecond)
+	minutes := total / int64(time.Minute/time.Millisecond)
+	total -= minutes * int64(time.Minute/time.Millisecond)
+	seconds := float64(total) / 1000
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if hours > 0 {
+		b.WriteString(strconv.FormatInt(hours, 10) + "H")
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatInt(minutes, 10) + "M")
+	}
+	if seconds > 0 || (hours == 0 && minutes == 0) {
+		b.WriteString(strconv.FormatFloat(seconds, 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
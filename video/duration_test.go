@@ -0,0 +1,43 @@
+package video
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDuration(t *testing.T) {
+	number := func(n float64) *float64 { return &n }
+	mp3 := dataSource{BinaryUrl: "http://ftvideo.example.com/0x0.mp3", Duration: number(68544), AudioOnly: true}
+	small := dataSource{BinaryUrl: "http://ftvideo.example.com/640x360.mp4", PixelWidth: number(640), PixelHeight: number(360), Duration: number(68587)}
+	large := dataSource{BinaryUrl: "http://ftvideo.example.com/1280x720.mp4", PixelWidth: number(1280), PixelHeight: number(720), Duration: number(68600)}
+	cut := dataSource{BinaryUrl: "http://ftvideo.example.com/cut.mp4", PixelWidth: number(320), PixelHeight: number(180), Duration: number(30000)}
+	hls := dataSource{BinaryUrl: "http://ftvideo.example.com/master.m3u8", Streaming: true, Duration: number(1)}
+
+	duration, err := getDuration([]dataSource{hls, mp3, small, large}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 68600.0, *duration)
+
+	duration, err = getDuration([]dataSource{mp3}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 68544.0, *duration)
+
+	duration, err = getDuration([]dataSource{mp3, cut, large}, 100*time.Millisecond)
+	assert.Equal(t, 68600.0, *duration)
+	assert.EqualError(t, err, "Renditions differ from the 68600 ms of http://ftvideo.example.com/1280x720.mp4 by more than 100ms: "+
+		"http://ftvideo.example.com/cut.mp4 lasts 30000 ms")
+	assert.Equal(t, warningInconsistent, warningCode(err))
+
+	duration, err = getDuration([]dataSource{hls}, time.Second)
+	assert.NoError(t, err)
+	assert.Nil(t, duration)
+}
+
+func TestISODuration(t *testing.T) {
+	assert.Equal(t, "PT1M8.587S", isoDuration(68587))
+	assert.Equal(t, "PT1H", isoDuration(3600000))
+	assert.Equal(t, "PT2H0.5S", isoDuration(7200500))
+	assert.Equal(t, "PT45S", isoDuration(45000))
+	assert.Equal(t, "PT0S", isoDuration(0))
+}
@@ -1,19 +1,75 @@
 package video
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
 	tid "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// errShuttingDown is returned for the messages handled after Shutdown.
+var errShuttingDown = errors.New("shutting down, the message is not sent")
+
 type VideoMapperHandler struct {
 	messageProducer    messageProducer
-	messageTransformer messageTransformer
+	messageTransformer contextTransformer
 	log                *logger.UPPLogger
+	ctx                context.Context
+	cancel             context.CancelFunc
+	metrics            mappingMetrics
+	tracer             trace.Tracer
+	feedbackProducer   messageProducer
+	deadLetterProducer messageProducer
+	panics             *PanicTracker
+}
+
+type HandlerOption func(*VideoMapperHandler)
+
+// WithHandlerMetrics records consumed, mapped, skipped and failed messages and the producer latency.
+func WithHandlerMetrics(m mappingMetrics) HandlerOption {
+	return func(v *VideoMapperHandler) {
+		v.metrics = m
+	}
+}
+
+// WithFeedbackProducer publishes the mapping warnings of every message to an editorial feedback topic.
+func WithFeedbackProducer(p messageProducer) HandlerOption {
+	return func(v *VideoMapperHandler) {
+		v.feedbackProducer = p
+	}
+}
+
+// WithDeadLetterProducer sends the messages whose handling panicked to a dead letter topic, instead of skipping them.
+func WithDeadLetterProducer(p messageProducer) HandlerOption {
+	return func(v *VideoMapperHandler) {
+		v.deadLetterProducer = p
+	}
+}
+
+// WithPanicTracker records the panics recovered while handling messages, for the health check.
+func WithPanicTracker(t *PanicTracker) HandlerOption {
+	return func(v *VideoMapperHandler) {
+		v.panics = t
+	}
+}
+
+// WithTracerProvider traces the messages with the given provider instead of the global one.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(v *VideoMapperHandler) {
+		v.tracer = tp.Tracer(tracerName)
+	}
 }
 
 type messageProducer interface {
@@ -24,63 +80,219 @@ type messageTransformer interface {
 	TransformMsg(kafka.FTMessage) (kafka.FTMessage, string, error)
 }
 
-func NewRequestHandler(messageProducer messageProducer, messageTransformer messageTransformer, log *logger.UPPLogger) *VideoMapperHandler {
-	return &VideoMapperHandler{
+type contextTransformer interface {
+	TransformMsgContext(context.Context, kafka.FTMessage) (kafka.FTMessage, string, []MappingWarning, error)
+}
+
+type mappingExplainer interface {
+	ExplainMsg(context.Context, kafka.FTMessage) (MappingExplanation, error)
+}
+
+// TransformerAdapter lets a transformer without context support be used by the handler.
+// The context is only checked before the transformation starts.
+type TransformerAdapter struct {
+	messageTransformer
+}
+
+func NewTransformerAdapter(t messageTransformer) TransformerAdapter {
+	return TransformerAdapter{t}
+}
+
+func (a TransformerAdapter) TransformMsgContext(ctx context.Context, m kafka.FTMessage) (kafka.FTMessage, string, []MappingWarning, error) {
+	if err := ctx.Err(); err != nil {
+		return kafka.FTMessage{}, "", nil, &mappingError{errorClassCancelled, err}
+	}
+	msg, contentUUID, err := a.TransformMsg(m)
+	return msg, contentUUID, nil, err
+}
+
+func NewRequestHandler(messageProducer messageProducer, messageTransformer contextTransformer, log *logger.UPPLogger, opts ...HandlerOption) *VideoMapperHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	v := &VideoMapperHandler{
 		messageProducer:    messageProducer,
 		messageTransformer: messageTransformer,
 		log:                log,
+		ctx:                ctx,
+		cancel:             cancel,
+		metrics:            noopMetrics{},
+		tracer:             otel.Tracer(tracerName),
+	}
+	for _, opt := range opts {
+		opt(v)
 	}
+	return v
 }
 
+// OnMessage handles a message of the consumer, whose errors are logged by HandleMessage.
+// The consumer must be closed before Shutdown, as the messages handled after it are not sent.
 func (v *VideoMapperHandler) OnMessage(m kafka.FTMessage) {
+	_ = v.HandleMessage(m)
+}
+
+// HandleMessage maps and sends a message. It returns nil when the message was sent or deliberately skipped,
+// and an error when it was blocked by the publication policy or could not be mapped or sent.
+func (v *VideoMapperHandler) HandleMessage(m kafka.FTMessage) (err error) {
+	v.metrics.MessageConsumed()
 	transactionID := m.Headers["X-Request-Id"]
+
+	ctx := otel.GetTextMapPropagator().Extract(v.ctx, propagation.MapCarrier(m.Headers))
+	ctx, span := v.tracer.Start(ctx, "OnMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrTransactionID.String(transactionID), attrOrigin.String(m.Headers["Origin-System-Id"])),
+	)
+	var spanErr error
+	defer func() { endSpan(span, spanErr) }()
+	// a message that makes the mapping panic must not take the consumer down with it
+	defer func() {
+		if r := recover(); r != nil {
+			spanErr = v.handlePanic(m, r)
+			err = spanErr
+		}
+	}()
+
 	if m.Headers["Origin-System-Id"] != systemOrigin {
 		v.log.WithTransactionID(transactionID).
 			WithField("Origin-System-Id", m.Headers["Origin-System-Id"]).
 			Info("Ignoring message with different Origin-System-Id")
-		return
+		v.metrics.MessageSkipped(skipReasonOrigin)
+		span.SetAttributes(attrSkipReason.String(skipReasonOrigin))
+		return nil
 	}
 	contentType := m.Headers["Content-Type"]
-	if strings.Contains(contentType, "application/json") {
-		videoMsg, contentUUID, err := v.messageTransformer.TransformMsg(m)
-		if err != nil {
-			v.log.WithTransactionID(transactionID).
-				WithError(err).
-				Errorf("Error consuming message")
-			return
-		}
-		err = v.messageProducer.SendMessage(videoMsg)
-		if err != nil {
-			v.log.WithTransactionID(transactionID).
-				WithError(err).
-				Error("Error sending transformed message to queue")
-			return
-		}
-		v.log.WithTransactionID(transactionID).
-			Infof("Mapped and sent for uuid: %v", contentUUID)
-	} else {
+	if !strings.Contains(contentType, "application/json") {
 		v.log.WithTransactionID(transactionID).
 			Infof("Ignoring message with contentType %v", contentType)
-		return
+		v.metrics.MessageSkipped(skipReasonContentType)
+		span.SetAttributes(attrSkipReason.String(skipReasonContentType))
+		return nil
+	}
+
+	start := time.Now()
+	transformCtx, transformSpan := v.tracer.Start(ctx, "TransformMsg")
+	videoMsg, contentUUID, warnings, err := v.messageTransformer.TransformMsgContext(transformCtx, m)
+	transformSpan.SetAttributes(attrContentUUID.String(contentUUID))
+	endSpan(transformSpan, err)
+	v.metrics.MappingDuration(time.Since(start))
+	span.SetAttributes(attrContentUUID.String(contentUUID))
+	if errorClass(err) == errorClassBlocked {
+		v.log.WithTransactionID(transactionID).
+			WithUUID(contentUUID).
+			WithError(err).
+			Warn("Video not sent as it is blocked by the publication policy")
+		v.metrics.MessageSkipped(skipReasonBlocked)
+		span.SetAttributes(attrSkipReason.String(skipReasonBlocked))
+		v.sendFeedback(transactionID, contentUUID, warnings)
+		return err
+	}
+	if errorClass(err) == errorClassCancelled {
+		spanErr = v.shuttingDown(transactionID, contentUUID)
+		return spanErr
+	}
+	if err != nil {
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Errorf("Error consuming message")
+		v.metrics.MessageFailed(errorClass(err))
+		spanErr = err
+		return err
+	}
+	if ctx.Err() != nil {
+		spanErr = v.shuttingDown(transactionID, contentUUID)
+		return spanErr
+	}
+	start = time.Now()
+	sendCtx, sendSpan := v.tracer.Start(ctx, "SendMessage", trace.WithSpanKind(trace.SpanKindProducer))
+	// legacy transformers may leave the headers nil
+	if videoMsg.Headers == nil {
+		videoMsg.Headers = map[string]string{}
 	}
+	otel.GetTextMapPropagator().Inject(sendCtx, propagation.MapCarrier(videoMsg.Headers))
+	err = v.messageProducer.SendMessage(videoMsg)
+	endSpan(sendSpan, err)
+	v.metrics.MessageProduced(time.Since(start), err)
+	if err != nil {
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Error("Error sending transformed message to queue")
+		v.metrics.MessageFailed(errorClassProduce)
+		spanErr = err
+		return err
+	}
+	v.metrics.MessageMapped()
+	v.log.WithTransactionID(transactionID).
+		Infof("Mapped and sent for uuid: %v", contentUUID)
+	v.sendFeedback(transactionID, contentUUID, warnings)
+	return nil
+}
+
+// shuttingDown reports a message that was consumed after Shutdown, and is neither mapped nor sent.
+func (v *VideoMapperHandler) shuttingDown(transactionID string, contentUUID string) error {
+	v.log.WithTransactionID(transactionID).
+		WithUUID(contentUUID).
+		Warn("Shutting down, the message is not sent")
+	v.metrics.MessageFailed(errorClassCancelled)
+	return errShuttingDown
 }
 
 func (v *VideoMapperHandler) MapRequest(w http.ResponseWriter, r *http.Request) {
 	transactionID := tid.GetTransactionIDFromRequest(r)
 	v.log.WithTransactionID(transactionID).Info("Received transformation request")
 
+	ctx, span, done := v.startRequest(r, "MapRequest", transactionID)
+	defer done()
+	var spanErr error
+	defer func() { endSpan(span, spanErr) }()
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		writerBadRequest(w, err, v.log)
+		spanErr = err
+		return
 	}
 
 	m := createConsumerMessageFromRequest(transactionID, body, r)
-	videoMsg, _, err := v.messageTransformer.TransformMsg(m)
+	transformCtx, transformSpan := v.tracer.Start(ctx, "TransformMsg")
+	videoMsg, contentUUID, warnings, err := v.messageTransformer.TransformMsgContext(transformCtx, m)
+	transformSpan.SetAttributes(attrContentUUID.String(contentUUID))
+	endSpan(transformSpan, err)
+	span.SetAttributes(attrContentUUID.String(contentUUID))
+	warningsInBody := r.URL.Query().Get("warnings") == "body"
+	if !warningsInBody {
+		writeWarningsHeader(w, warnings)
+	}
+	if errorClass(err) == errorClassBlocked {
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Warn("Video blocked by the publication policy")
+		if warningsInBody {
+			v.writeMappingResponse(w, http.StatusUnprocessableEntity, mappingResponse{Error: err.Error(), Warnings: warnings})
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if _, err := w.Write([]byte(err.Error())); err != nil {
+			v.log.WithError(err).Warn("Couldn't write Unprocessable Entity response.")
+		}
+		return
+	}
+	if errorClass(err) == errorClassCancelled {
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Warn("Transformation request cancelled")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		spanErr = err
+		return
+	}
 	if err != nil {
 		v.log.WithError(err).Error("Failed to transform message")
 		writerBadRequest(w, err, v.log)
+		spanErr = err
+		return
 	}
 
+	if warningsInBody {
+		v.writeMappingResponse(w, http.StatusOK, mappingResponse{Message: json.RawMessage(videoMsg.Body), Warnings: warnings})
+		return
+	}
 	w.Header().Add("Content-Type", "application/json")
 	_, err = w.Write([]byte(videoMsg.Body))
 	if err != nil {
@@ -90,6 +302,137 @@ func (v *VideoMapperHandler) MapRequest(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (v *VideoMapperHandler) writeMappingResponse(w http.ResponseWriter, status int, res mappingResponse) {
+	if res.Warnings == nil {
+		res.Warnings = []MappingWarning{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		v.log.WithError(err).Warn("Writing response error")
+	}
+}
+
+// ExplainRequest maps the native video in the request body and returns the mapped message
+// together with the provenance of every payload field.
+func (v *VideoMapperHandler) ExplainRequest(w http.ResponseWriter, r *http.Request) {
+	transactionID := tid.GetTransactionIDFromRequest(r)
+	v.log.WithTransactionID(transactionID).Info("Received explain request")
+
+	ctx, span, done := v.startRequest(r, "ExplainRequest", transactionID)
+	defer done()
+	var spanErr error
+	defer func() { endSpan(span, spanErr) }()
+
+	explainer, ok := v.messageTransformer.(mappingExplainer)
+	if !ok {
+		http.Error(w, "mapping explanation is not supported", http.StatusNotImplemented)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writerBadRequest(w, err, v.log)
+		spanErr = err
+		return
+	}
+
+	m := createConsumerMessageFromRequest(transactionID, body, r)
+	explanation, err := explainer.ExplainMsg(ctx, m)
+	status := http.StatusOK
+	switch {
+	case errorClass(err) == errorClassBlocked:
+		// the explanation tells why the video is blocked
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Warn("Video blocked by the publication policy")
+		status = http.StatusUnprocessableEntity
+	case errorClass(err) == errorClassCancelled:
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Warn("Explain request cancelled")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		spanErr = err
+		return
+	case err != nil:
+		v.log.WithTransactionID(transactionID).WithError(err).Error("Failed to explain message")
+		writerBadRequest(w, err, v.log)
+		spanErr = err
+		return
+	}
+
+	span.SetAttributes(attrContentUUID.String(explanation.UUID))
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(explanation); err != nil {
+		v.log.WithTransactionID(transactionID).
+			WithError(err).
+			Warn("Writing response error")
+	}
+}
+
+// startRequest starts the span of a request, with a context cancelled when either the request is done
+// or the handler shuts down. done has to be called once the request is handled.
+func (v *VideoMapperHandler) startRequest(r *http.Request, spanName string, transactionID string) (context.Context, trace.Span, func()) {
+	ctx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(v.ctx, cancel)
+	// AfterFunc calls cancel in its own goroutine, too late for a request coming after the shutdown
+	if v.ctx.Err() != nil {
+		cancel()
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	ctx, span := v.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrTransactionID.String(transactionID)),
+	)
+	return ctx, span, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sendFeedback publishes the warnings of a video that was sent or blocked by the publication policy,
+// so editors learn what was dropped, defaulted or refused.
+func (v *VideoMapperHandler) sendFeedback(transactionID string, contentUUID string, warnings []MappingWarning) {
+	if v.feedbackProducer == nil || len(warnings) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(mappingFeedback{
+		UUID:          contentUUID,
+		TransactionID: transactionID,
+		Warnings:      warnings,
+	})
+	if err != nil {
+		v.log.WithTransactionID(transactionID).WithError(err).Warn("Couldn't marshal mapping feedback")
+		return
+	}
+
+	headers := map[string]string{
+		"X-Request-Id":      transactionID,
+		"Message-Timestamp": time.Now().Format(dateFormat),
+		"Message-Id":        uuid.New().String(),
+		"Message-Type":      feedbackMessageType,
+		"Content-Type":      "application/json",
+		"Origin-System-Id":  systemOrigin,
+	}
+	if err := v.feedbackProducer.SendMessage(kafka.FTMessage{Headers: headers, Body: string(body)}); err != nil {
+		v.log.WithTransactionID(transactionID).
+			WithUUID(contentUUID).
+			WithError(err).
+			Warn("Couldn't send mapping feedback")
+	}
+}
+
+// Shutdown cancels the mappings in flight. It has to be called once the source is closed and drained:
+// the messages handled afterwards are not sent.
+func (v *VideoMapperHandler) Shutdown() {
+	v.cancel()
+}
+
 func createConsumerMessageFromRequest(tid string, body []byte, r *http.Request) kafka.FTMessage {
 	return kafka.FTMessage{
 		Body: string(body),
@@ -101,6 +444,36 @@ func createConsumerMessageFromRequest(tid string, body []byte, r *http.Request)
 	}
 }
 
+// writeWarningsHeader sets the warnings as a JSON array in the X-Mapping-Warnings header. The warnings
+// that don't fit in maxWarningsHeaderSize are left out, and counted in the X-Mapping-Warnings-Omitted header.
+func writeWarningsHeader(w http.ResponseWriter, warnings []MappingWarning) {
+	if len(warnings) == 0 {
+		return
+	}
+	var encoded strings.Builder
+	kept := 0
+	for _, warning := range warnings {
+		item, err := json.Marshal(warning)
+		// room is kept for the separator and the closing bracket
+		if err != nil || encoded.Len()+len(item)+2 > maxWarningsHeaderSize {
+			break
+		}
+		if kept == 0 {
+			encoded.WriteString("[")
+		} else {
+			encoded.WriteString(",")
+		}
+		encoded.Write(item)
+		kept++
+	}
+	if kept > 0 {
+		w.Header().Set(mappingWarningsHeader, encoded.String()+"]")
+	}
+	if omitted := len(warnings) - kept; omitted > 0 {
+		w.Header().Set(omittedWarningsHeader, strconv.Itoa(omitted))
+	}
+}
+
 func writerBadRequest(w http.ResponseWriter, err error, log *logger.UPPLogger) {
 	w.WriteHeader(http.StatusBadRequest)
 	_, err = w.Write([]byte(err.Error()))
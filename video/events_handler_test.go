@@ -1,20 +1,36 @@
 package video
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type mockMessageProducer struct {
 	message    string
+	headers    map[string]string
 	sendCalled bool
+	err        error
 }
 
 func TestNewVideoMapperHandler(t *testing.T) {
@@ -44,6 +60,30 @@ func TestMapHandler_InvalidBody(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, res.Code, "Unexpected status code")
 }
 
+func TestMapHandler_ReadError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map", iotest.ErrReader(errors.New("connection reset")))
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	requestHandler.MapRequest(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "connection reset", res.Body.String(), "Nothing should be mapped once the request body couldn't be read")
+}
+
+func TestMapHandler_MappingError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map", strings.NewReader(`{`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	requestHandler.MapRequest(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.NotEqual(t, "application/json", res.Header().Get("Content-Type"), "The failure should not be followed by a mapped response")
+	assert.Contains(t, res.Body.String(), "Video JSON couldn't be unmarshalled")
+}
+
 func TestOnMessage_InvalidSystemId(t *testing.T) {
 	m := kafka.FTMessage{
 		Headers: map[string]string{
@@ -118,10 +158,406 @@ func TestOnMessage_Success(t *testing.T) {
 	}
 }
 
+func TestOnMessage_Metrics(t *testing.T) {
+	videoInput, err := readContent("video-input.json")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Input data for test cannot be loaded from external file")
+	}
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: videoInput,
+	}
+
+	metrics := &mockMetrics{skipped: map[string]int{}, failed: map[string]int{}}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(&mockMessageProducer{}, VideoMapper{log: log}, log, WithHandlerMetrics(metrics))
+
+	handler.OnMessage(m)
+	handler.OnMessage(kafka.FTMessage{Headers: map[string]string{"Origin-System-Id": systemOrigin, "Content-Type": "text/plain"}})
+	handler.OnMessage(kafka.FTMessage{Headers: map[string]string{"Origin-System-Id": "other"}})
+	handler.OnMessage(kafka.FTMessage{Headers: map[string]string{"Origin-System-Id": systemOrigin, "Content-Type": "application/json"}, Body: "{}"})
+
+	assert.Equal(t, 4, metrics.consumed)
+	assert.Equal(t, 1, metrics.mapped)
+	assert.Equal(t, 1, metrics.produced)
+	assert.Equal(t, map[string]int{skipReasonContentType: 1, skipReasonOrigin: 1}, metrics.skipped)
+	assert.Equal(t, map[string]int{errorClassMissingTransactionID: 1}, metrics.failed)
+}
+
+func TestOnMessage_Tracing(t *testing.T) {
+	videoInput, err := readContent("video-input.json")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Input data for test cannot be loaded from external file")
+	}
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+			"traceparent":       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		Body: videoInput,
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	producer := &mockMessageProducer{}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, VideoMapper{log: log}, log, WithTracerProvider(tp))
+
+	handler.OnMessage(m)
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 3) {
+		assert.Equal(t, "TransformMsg", spans[0].Name)
+		assert.Equal(t, "SendMessage", spans[1].Name)
+		assert.Equal(t, "OnMessage", spans[2].Name)
+		for _, s := range spans {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.SpanContext.TraceID().String(), "Incoming trace should be continued")
+		}
+		assert.Contains(t, spans[2].Attributes, attrTransactionID.String(xRequestId))
+		assert.Contains(t, spans[2].Attributes, attrContentUUID.String("a40808ac-1417-4c48-9781-1dd2d8c8c6dc"))
+		assert.Contains(t, spans[2].Attributes, attrOrigin.String(systemOrigin))
+		assert.Contains(t, producer.headers["traceparent"], spans[1].SpanContext.SpanID().String(), "Trace context should be injected in the produced message")
+	}
+}
+
+func TestOnMessage_AfterShutdown(t *testing.T) {
+	videoInput, err := readContent("video-input.json")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Input data for test cannot be loaded from external file")
+	}
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: videoInput,
+	}
+
+	eventsHandler, mockMsgProducer := createRequestHandler()
+	eventsHandler.Shutdown()
+
+	assert.ErrorIs(t, eventsHandler.HandleMessage(m), errShuttingDown)
+	assert.NotPanics(t, func() { eventsHandler.OnMessage(m) }, "A message that comes in late should not crash the service")
+	assert.False(t, mockMsgProducer.sendCalled, "Messages should not be mapped after shutdown")
+}
+
+func TestOnMessage_LegacyTransformer(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":     xRequestId,
+			"Origin-System-Id": systemOrigin,
+			"Content-Type":     "application/json",
+		},
+		Body: `{}`,
+	}
+
+	producer := &mockMessageProducer{}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, NewTransformerAdapter(&mockLegacyTransformer{}), log)
+	handler.OnMessage(m)
+
+	assert.True(t, producer.sendCalled)
+	assert.Equal(t, "legacy", producer.message)
+}
+
+func TestOnMessage_LegacyTransformerWithoutHeaders(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":     xRequestId,
+			"Origin-System-Id": systemOrigin,
+			"Content-Type":     "application/json",
+		},
+		Body: `{}`,
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(tracetest.NewInMemoryExporter()))
+	producer := &mockMessageProducer{}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, NewTransformerAdapter(&mockLegacyTransformer{noHeaders: true}), log, WithTracerProvider(tp))
+
+	assert.NoError(t, handler.HandleMessage(m), "The trace context should be injected in new headers")
+	assert.True(t, producer.sendCalled)
+	assert.NotEmpty(t, producer.headers["traceparent"])
+}
+
+func TestMapHandler_CancelledRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/map", strings.NewReader(`{"id":"a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`)).WithContext(ctx)
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	requestHandler.MapRequest(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code, "Cancelled request should not be mapped")
+}
+
+func TestOnMessage_Feedback(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: `{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true, "related": [], "image": "bad"}`,
+	}
+
+	producer := &mockMessageProducer{}
+	feedback := &mockMessageProducer{}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, VideoMapper{log: log}, log, WithFeedbackProducer(feedback))
+	handler.OnMessage(m)
+
+	assert.True(t, producer.sendCalled)
+	if assert.True(t, feedback.sendCalled, "Warnings should be published as feedback") {
+		assert.Equal(t, feedbackMessageType, feedback.headers["Message-Type"])
+		assert.Equal(t, xRequestId, feedback.headers["X-Request-Id"])
+		assert.JSONEq(t, `{
+			"uuid": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+			"transactionId": "tid_123123",
+			"warnings": [
+				{"field": "mainImage", "code": "invalid_format", "message": "Extract main image: invalid image format: bad"},
+				{"field": "transcript", "code": "missing", "message": "Transcription is null and will be skipped for uuid: a40808ac-1417-4c48-9781-1dd2d8c8c6dc"},
+				{"field": "dataSource", "code": "missing", "message": "Encodings field of video JSON is null, dataSource will be empty."}
+			]
+		}`, feedback.message)
+	}
+}
+
+func TestOnMessage_FeedbackOnlyForSentMessages(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: `{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true, "related": [], "image": "bad"}`,
+	}
+
+	producer := &mockMessageProducer{err: errors.New("broker is down")}
+	feedback := &mockMessageProducer{}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, VideoMapper{log: log}, log, WithFeedbackProducer(feedback))
+
+	assert.Error(t, handler.HandleMessage(m))
+	assert.False(t, feedback.sendCalled, "No feedback should be sent when the message couldn't be sent")
+
+	producer.err = nil
+	assert.NoError(t, handler.HandleMessage(m))
+	assert.True(t, feedback.sendCalled)
+}
+
+func TestOnMessage_BlockedByPolicy(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: `{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true, "related": [], "transcription": {}, "encoding": {"outputs": []}}`,
+	}
+
+	producer := &mockMessageProducer{}
+	feedback := &mockMessageProducer{}
+	metrics := &mockMetrics{skipped: map[string]int{}, failed: map[string]int{}, warnings: map[string]int{}}
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	mapper := NewVideoMapper(log, WithPolicy(Policy{Rules: []PolicyRule{{Rule: RuleTitleRequired, Severity: SeverityBlock}}}))
+	handler := NewRequestHandler(producer, mapper, log, WithFeedbackProducer(feedback), WithHandlerMetrics(metrics))
+	handler.OnMessage(m)
+
+	assert.False(t, producer.sendCalled, "Blocked videos should not be sent")
+	assert.Equal(t, 1, metrics.skipped[skipReasonBlocked])
+	if assert.True(t, feedback.sendCalled, "Block reasons should be published as feedback") {
+		assert.Contains(t, feedback.message, "Publication rule title-required: the title is missing")
+	}
+}
+
+func TestOnMessage_Panic(t *testing.T) {
+	m := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Origin-System-Id":  systemOrigin,
+			"Message-Timestamp": messageTimestamp,
+			"Content-Type":      "application/json",
+		},
+		Body: `{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`,
+	}
+
+	producer := &mockMessageProducer{}
+	deadLetters := &mockMessageProducer{}
+	metrics := &mockMetrics{skipped: map[string]int{}, failed: map[string]int{}, warnings: map[string]int{}}
+	panics := NewPanicTracker(0, time.Minute)
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(producer, &mockPanickingTransformer{}, log,
+		WithHandlerMetrics(metrics), WithDeadLetterProducer(deadLetters), WithPanicTracker(panics))
+
+	assert.NotPanics(t, func() { handler.OnMessage(m) })
+
+	assert.False(t, producer.sendCalled, "Nothing should be sent when the mapping panics")
+	assert.Equal(t, 1, metrics.panicked)
+	assert.Equal(t, 1, metrics.failed[errorClassPanic])
+	assert.Error(t, panics.Check())
+	if assert.True(t, deadLetters.sendCalled, "The message should be dead-lettered") {
+		assert.Equal(t, m.Body, deadLetters.message)
+		assert.Equal(t, xRequestId, deadLetters.headers["X-Request-Id"])
+		assert.Equal(t, "panic while handling message: mapping exploded", deadLetters.headers[deadLetterReasonHeader])
+	}
+	assert.NotContains(t, m.Headers, deadLetterReasonHeader, "The consumed message should not be changed")
+}
+
+func TestMapHandler_WarningsHeader(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map", strings.NewReader(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true, "related": [], "transcription": {}, "encoding": {"outputs": []}}`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	requestHandler.MapRequest(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `[
+		{"field": "mainImage", "code": "missing", "message": "Extract main image: [image] field of native video JSON is null"},
+		{"field": "transcript", "code": "missing", "message": "[transcript] field of native video JSON is null"}
+	]`, res.Header().Get(mappingWarningsHeader))
+}
+
+func TestWriteWarningsHeader_Truncated(t *testing.T) {
+	var warnings []MappingWarning
+	for i := 0; i < 500; i++ {
+		warnings = append(warnings, MappingWarning{Field: "transcript", Code: warningInvalidXHTML, Message: fmt.Sprintf("element %d is not allowed in the transcript", i)})
+	}
+	res := httptest.NewRecorder()
+
+	writeWarningsHeader(res, warnings)
+
+	header := res.Header().Get(mappingWarningsHeader)
+	assert.LessOrEqual(t, len(header), maxWarningsHeaderSize)
+	var kept []MappingWarning
+	require.NoError(t, json.Unmarshal([]byte(header), &kept), "The truncated header should still be a JSON array")
+	assert.Equal(t, warnings[:len(kept)], kept)
+	assert.Equal(t, strconv.Itoa(len(warnings)-len(kept)), res.Header().Get(omittedWarningsHeader))
+}
+
+func TestMapHandler_WarningsInBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map?warnings=body", strings.NewReader(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true, "related": [], "transcription": {}, "encoding": {"outputs": []}}`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	requestHandler.MapRequest(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, res.Header().Get(mappingWarningsHeader), "The warnings should only be in the body")
+	var body mappingResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+	assert.Contains(t, string(body.Message), `"uuid":"a40808ac-1417-4c48-9781-1dd2d8c8c6dc"`)
+	assert.Equal(t, []MappingWarning{
+		{Field: "mainImage", Code: warningMissing, Message: "Extract main image: [image] field of native video JSON is null"},
+		{Field: "transcript", Code: warningMissing, Message: "[transcript] field of native video JSON is null"},
+	}, body.Warnings)
+}
+
+func TestExplainHandler(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map/explain", strings.NewReader(`{
+		"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+		"title": "ECB and Fed debates hit dollar and euro",
+		"image": "77d86b7c-dfed-4a9b-a4b6-156d4afefd8c"
+	}`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	requestHandler, _ := createRequestHandler()
+	r := mux.NewRouter()
+	r.HandleFunc("/map/explain", requestHandler.ExplainRequest).Methods("POST")
+	r.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	var explanation MappingExplanation
+	if assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &explanation)) {
+		assert.Equal(t, "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", explanation.UUID)
+		assert.Contains(t, string(explanation.Message), `"title":"ECB and Fed debates hit dollar and euro"`)
+		assert.Contains(t, explanation.Fields, FieldProvenance{Field: "title", Source: "$.title", Rule: ruleCopy})
+		assert.Contains(t, explanation.Fields, FieldProvenance{Field: "mainImage", Source: "$.image", Rule: ruleUUID})
+		assert.Contains(t, explanation.Fields, FieldProvenance{Field: "lastModified", Rule: ruleNow, Defaulted: true})
+		assert.Contains(t, explanation.Fields, FieldProvenance{Field: "canBeSyndicated", Source: "$.canBeSyndicated", Rule: ruleYesNo, Defaulted: true})
+		assert.NotContains(t, explanation.Fields, FieldProvenance{Field: "transcript", Source: "$.transcription.transcript", Rule: ruleBodyXML}, "Fields missing from the payload should not be explained")
+		assert.NotEmpty(t, explanation.Warnings)
+	}
+}
+
+func TestExplainHandler_BlockedByPolicy(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map/explain", strings.NewReader(`{
+		"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+		"image": "77d86b7c-dfed-4a9b-a4b6-156d4afefd8c"
+	}`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	mapper := NewVideoMapper(log, WithPolicy(Policy{Rules: []PolicyRule{{Rule: RuleTitleRequired, Severity: SeverityBlock}}}))
+	handler := NewRequestHandler(&mockMessageProducer{}, mapper, log)
+	handler.ExplainRequest(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "Blocked videos should be answered like /map does")
+	var explanation MappingExplanation
+	if assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &explanation)) {
+		assert.Equal(t, []string{"Publication rule title-required: the title is missing"}, explanation.Blocked)
+		assert.Contains(t, explanation.Fields, FieldProvenance{Field: "mainImage", Source: "$.image", Rule: ruleUUID})
+		assert.Contains(t, explanation.Warnings, MappingWarning{Field: "title", Code: warningPolicyBlocked, Message: "Publication rule title-required: the title is missing"})
+	}
+}
+
+func TestExplainHandler_AfterShutdown(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map/explain", strings.NewReader(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`))
+	req.Header.Set("X-Request-Id", xRequestId)
+	res := httptest.NewRecorder()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(&mockMessageProducer{}, VideoMapper{log: log}, log, WithTracerProvider(tp))
+	handler.Shutdown()
+	handler.ExplainRequest(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code, "Requests should not be explained after shutdown")
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "ExplainRequest", spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Contains(t, spans[0].Attributes, attrTransactionID.String(xRequestId))
+	}
+}
+
+func TestExplainHandler_NotSupported(t *testing.T) {
+	req := httptest.NewRequest("POST", "/map/explain", strings.NewReader(`{}`))
+	res := httptest.NewRecorder()
+
+	log := logger.NewUPPLogger("video-mapper", "Debug")
+	handler := NewRequestHandler(&mockMessageProducer{}, NewTransformerAdapter(&mockLegacyTransformer{}), log)
+	handler.ExplainRequest(res, req)
+
+	assert.Equal(t, http.StatusNotImplemented, res.Code)
+}
+
 func (mock *mockMessageProducer) SendMessage(message kafka.FTMessage) error {
 	mock.message = message.Body
+	mock.headers = message.Headers
 	mock.sendCalled = true
-	return nil
+	return mock.err
 }
 
 func (mock *mockMessageProducer) ConnectivityCheck() (string, error) {
@@ -145,3 +581,39 @@ func readContent(fileName string) (string, error) {
 
 	return string(data), nil
 }
+
+type mockMetrics struct {
+	consumed int
+	mapped   int
+	produced int
+	skipped  map[string]int
+	failed   map[string]int
+	warnings map[string]int
+	panicked int
+}
+
+func (m *mockMetrics) MessageConsumed()                     { m.consumed++ }
+func (m *mockMetrics) MessageMapped()                       { m.mapped++ }
+func (m *mockMetrics) MessageSkipped(reason string)         { m.skipped[reason]++ }
+func (m *mockMetrics) MessageFailed(class string)           { m.failed[class]++ }
+func (m *mockMetrics) MessageProduced(time.Duration, error) { m.produced++ }
+func (m *mockMetrics) MappingDuration(time.Duration)        {}
+func (m *mockMetrics) MappingWarning(field string)          { m.warnings[field]++ }
+func (m *mockMetrics) MessagePanicked()                     { m.panicked++ }
+
+type mockPanickingTransformer struct{}
+
+func (mock *mockPanickingTransformer) TransformMsgContext(context.Context, kafka.FTMessage) (kafka.FTMessage, string, []MappingWarning, error) {
+	panic("mapping exploded")
+}
+
+type mockLegacyTransformer struct {
+	noHeaders bool
+}
+
+func (mock *mockLegacyTransformer) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, error) {
+	if mock.noHeaders {
+		return kafka.FTMessage{Body: "legacy"}, "uuid", nil
+	}
+	return kafka.FTMessage{Headers: map[string]string{}, Body: "legacy"}, "uuid", nil
+}
@@ -0,0 +1,114 @@
+package video
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goldenDir = "testdata/golden"
+
+var update = flag.Bool("update", false, "regenerate the expected output of the golden files")
+
+// goldenOutput is what a golden case expects TransformMsg to return.
+type goldenOutput struct {
+	UUID     string            `json:"uuid,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	Warnings []MappingWarning  `json:"warnings,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// TestTransformMsg_Golden maps every <case>.input.json of the golden directory with the headers of
+// <case>.headers.json, and the publication policy of <case>.policy.json when there is one, and compares
+// the result with <case>.output.json. Run with -update to regenerate the expected output after an
+// intended change of the mapping.
+func TestTransformMsg_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*.input.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs, "no golden cases found in %v", goldenDir)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.json")
+		t.Run(name, func(t *testing.T) {
+			message := readGoldenMessage(t, name)
+			actual := goldenMapping(t, message, readGoldenPolicy(t, name)...)
+
+			expectedFile := filepath.Join(goldenDir, name+".output.json")
+			if *update {
+				require.NoError(t, os.WriteFile(expectedFile, actual, 0644))
+				return
+			}
+			expected, err := os.ReadFile(expectedFile)
+			require.NoError(t, err, "missing expected output, run the tests with -update to create it")
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func readGoldenMessage(t *testing.T, name string) kafka.FTMessage {
+	body, err := os.ReadFile(filepath.Join(goldenDir, name+".input.json"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(goldenDir, name+".headers.json"))
+	require.NoError(t, err)
+	var headers map[string]string
+	require.NoError(t, json.Unmarshal(data, &headers))
+
+	return kafka.FTMessage{Headers: headers, Body: string(body)}
+}
+
+// readGoldenPolicy returns the option setting the publication policy of the case, if it has one.
+func readGoldenPolicy(t *testing.T, name string) []MapperOption {
+	path := filepath.Join(goldenDir, name+".policy.json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	policy, err := LoadPolicy(path)
+	require.NoError(t, err)
+	return []MapperOption{WithPolicy(policy)}
+}
+
+// goldenMapping maps message with a fixed clock and message IDs, so that the output only depends on the input.
+func goldenMapping(t *testing.T, message kafka.FTMessage, opts ...MapperOption) []byte {
+	clock := func() time.Time { return time.Date(2017, 4, 13, 10, 27, 32, 353000000, time.UTC) }
+	ids := 0
+	messageID := func() string {
+		ids++
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", ids)
+	}
+	opts = append([]MapperOption{WithClock(clock), WithMessageIDGenerator(messageID)}, opts...)
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Error"), opts...)
+
+	mapped, uuid, warnings, err := m.TransformMsg(message)
+	output := goldenOutput{
+		UUID:     uuid,
+		Headers:  mapped.Headers,
+		Warnings: warnings,
+	}
+	if mapped.Body != "" {
+		output.Body = json.RawMessage(mapped.Body)
+	}
+	if err != nil {
+		output.Error = err.Error()
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(output))
+	return buf.Bytes()
+}
@@ -18,6 +18,16 @@ type HealthCheck struct {
 	producer      messageProducerHealthcheck
 	appName       string
 	appSystemCode string
+	panics        *PanicTracker
+}
+
+type HealthCheckOption func(*HealthCheck)
+
+// WithPanicCheck turns the health check red when messages keep making the mapping panic.
+func WithPanicCheck(t *PanicTracker) HealthCheckOption {
+	return func(h *HealthCheck) {
+		h.panics = t
+	}
 }
 
 type messageProducerHealthcheck interface {
@@ -29,17 +39,24 @@ type messageConsumerHealthcheck interface {
 	MonitorCheck() error
 }
 
-func NewHealthCheck(p messageProducerHealthcheck, c messageConsumerHealthcheck, appName string, appSystemCode string) *HealthCheck {
-	return &HealthCheck{
+func NewHealthCheck(p messageProducerHealthcheck, c messageConsumerHealthcheck, appName string, appSystemCode string, opts ...HealthCheckOption) *HealthCheck {
+	h := &HealthCheck{
 		consumer:      c,
 		producer:      p,
 		appName:       appName,
 		appSystemCode: appSystemCode,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
 	checks := []fthealth.Check{h.readQueueCheck(), h.readQueueLagCheck(), h.writeQueueCheck()}
+	if h.panics != nil {
+		checks = append(checks, h.panicCheck())
+	}
 	hc := fthealth.TimedHealthCheck{
 		HealthCheck: fthealth.HealthCheck{
 			SystemCode:  h.appSystemCode,
@@ -88,6 +105,18 @@ func (h *HealthCheck) readQueueLagCheck() fthealth.Check {
 	}
 }
 
+func (h *HealthCheck) panicCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "mapping-panics",
+		Name:             "Messages Are Mapped Without Panicking",
+		Severity:         2,
+		BusinessImpact:   "Some videos are not published or updated, clients will not see the new content.",
+		TechnicalSummary: "Messages keep making the mapping panic. Check the logs for the stack traces, and the dead letter topic if there is one.",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", h.appSystemCode),
+		Checker:          h.checkPanics,
+	}
+}
+
 func (h *HealthCheck) GTG() gtg.Status {
 	consumerCheck := func() gtg.Status {
 		return gtgCheck(h.checkIfKafkaIsReachableFromConsumer)
@@ -132,3 +161,10 @@ func (h *HealthCheck) checkIfKafkaIsReachableFromProducer() (string, error) {
 	}
 	return ResponseOK, nil
 }
+
+func (h *HealthCheck) checkPanics() (string, error) {
+	if err := h.panics.Check(); err != nil {
+		return "", err
+	}
+	return ResponseOK, nil
+}
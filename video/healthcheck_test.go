@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,6 +72,23 @@ func TestUnhappyHealthCheck(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"name":"Read Message Queue Is Not Lagging","ok":false`, "Read message queue is not lagging healthcheck should be unhappy")
 }
 
+func TestHealthCheckWithPanics(t *testing.T) {
+	panics := NewPanicTracker(1, time.Minute)
+	hc := NewHealthCheck(&mockProducerInstance{isConnectionHealthy: true}, &mockConsumerInstance{isConnectionHealthy: true, isNotLagging: true}, "app", "app", WithPanicCheck(panics))
+
+	health := func() string {
+		w := httptest.NewRecorder()
+		hc.Health()(w, httptest.NewRequest("GET", "http://example.com/__health", nil))
+		return w.Body.String()
+	}
+
+	panics.record()
+	assert.Contains(t, health(), `"name":"Messages Are Mapped Without Panicking","ok":true`, "A panic at the threshold should be tolerated")
+	panics.record()
+	assert.Contains(t, health(), `"name":"Messages Are Mapped Without Panicking","ok":false`, "Panics over the threshold should turn the check red")
+	assert.True(t, hc.GTG().GoodToGo, "Panics should not take the service out of the load balancer")
+}
+
 func TestGTGHappyFlow(t *testing.T) {
 	hc := initializeHealthCheck(true, true, true)
 
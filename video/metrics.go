@@ -0,0 +1,34 @@
+package video
+
+import "time"
+
+const (
+	skipReasonOrigin      = "origin"
+	skipReasonContentType = "content_type"
+	skipReasonBlocked     = "blocked"
+
+	errorClassProduce = "produce"
+	errorClassPanic   = "panic"
+)
+
+type mappingMetrics interface {
+	MessageConsumed()
+	MessageMapped()
+	MessageSkipped(reason string)
+	MessageFailed(class string)
+	MessageProduced(d time.Duration, err error)
+	MappingDuration(d time.Duration)
+	MappingWarning(field string)
+	MessagePanicked()
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) MessageConsumed()                     {}
+func (noopMetrics) MessageMapped()                       {}
+func (noopMetrics) MessageSkipped(string)                {}
+func (noopMetrics) MessageFailed(string)                 {}
+func (noopMetrics) MessageProduced(time.Duration, error) {}
+func (noopMetrics) MappingDuration(time.Duration)        {}
+func (noopMetrics) MappingWarning(string)                {}
+func (noopMetrics) MessagePanicked()                     {}
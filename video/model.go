@@ -1,6 +1,15 @@
 package video
 
-const systemOrigin = "http://cmdb.ft.com/systems/next-video-editor"
+import "encoding/json"
+
+const (
+	systemOrigin          = "http://cmdb.ft.com/systems/next-video-editor"
+	feedbackMessageType   = "next-video-mapping-feedback"
+	mappingWarningsHeader = "X-Mapping-Warnings"
+	omittedWarningsHeader = "X-Mapping-Warnings-Omitted"
+	// maxWarningsHeaderSize keeps the warnings header within what proxies and clients accept.
+	maxWarningsHeaderSize = 4096
+)
 
 type publicationEvent struct {
 	ContentURI   string        `json:"contentUri"`
@@ -8,6 +17,19 @@ type publicationEvent struct {
 	LastModified string        `json:"lastModified"`
 }
 
+type mappingFeedback struct {
+	UUID          string           `json:"uuid"`
+	TransactionID string           `json:"transactionId"`
+	Warnings      []MappingWarning `json:"warnings"`
+}
+
+// mappingResponse is the body of /map?warnings=body, which carries all the warnings however many there are.
+type mappingResponse struct {
+	Message  json.RawMessage  `json:"message,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Warnings []MappingWarning `json:"warnings"`
+}
+
 type identifier struct {
 	Authority       string `json:"authority"`
 	IdentifierValue string `json:"identifierValue"`
@@ -26,38 +48,76 @@ type alternativeStandfirsts struct {
 }
 
 type videoPayload struct {
-	ID                    string                  `json:"uuid,omitempty"`
-	Title                 string                  `json:"title,omitempty"`
-	Standfirst            string                  `json:"standfirst,omitempty"`
-	Description           string                  `json:"description,omitempty"`
-	Byline                string                  `json:"byline,omitempty"`
-	Identifiers           []identifier            `json:"identifiers,omitempty"`
-	Brands                []brand                 `json:"brands,omitempty"`
-	FirstPublishedDate    string                  `json:"firstPublishedDate,omitempty"`
-	PublishedDate         string                  `json:"publishedDate,omitempty"`
-	MainImage             string                  `json:"mainImage,omitempty"`
-	StoryPackage          string                  `json:"storyPackage,omitempty"`
-	Transcript            string                  `json:"transcript,omitempty"`
-	Captions              []caption               `json:"captions,omitempty"`
-	DataSources           []dataSource            `json:"dataSource,omitempty"`
-	CanBeDistributed      string                  `json:"canBeDistributed,omitempty"`
-	Type                  string                  `json:"type,omitempty"`
-	LastModified          string                  `json:"lastModified,omitempty"`
-	PublishReference      string                  `json:"publishReference,omitempty"`
-	CanBeSyndicated       string                  `json:"canBeSyndicated,omitempty"`
-	AccessLevel           string                  `json:"accessLevel,omitempty"`
-	WebURL                string                  `json:"webUrl,omitempty"`
-	CanonicalWebURL       string                  `json:"canonicalWebUrl,omitempty"`
-	AlternativeTitles     *alternativeTitles      `json:"alternativeTitles,omitempty"`
-	AlternativeStandfirst *alternativeStandfirsts `json:"alternativeStandfirsts,omitempty"`
-	Deleted               bool                    `json:"deleted,omitempty"`
+	ID                      string                  `json:"uuid,omitempty"`
+	Title                   string                  `json:"title,omitempty"`
+	Standfirst              string                  `json:"standfirst,omitempty"`
+	Description             string                  `json:"description,omitempty"`
+	Byline                  string                  `json:"byline,omitempty"`
+	Identifiers             []identifier            `json:"identifiers,omitempty"`
+	Brands                  []brand                 `json:"brands,omitempty"`
+	FirstPublishedDate      string                  `json:"firstPublishedDate,omitempty"`
+	PublishedDate           string                  `json:"publishedDate,omitempty"`
+	MainImage               string                  `json:"mainImage,omitempty"`
+	MainImageFallback       string                  `json:"mainImageFallback,omitempty"`
+	StoryPackage            string                  `json:"storyPackage,omitempty"`
+	Transcript              string                  `json:"transcript,omitempty"`
+	TranscriptAutoGenerated bool                    `json:"transcriptAutoGenerated,omitempty"`
+	TranscriptSegments      []transcriptSegment     `json:"transcriptSegments,omitempty"`
+	Captions                []caption               `json:"captions,omitempty"`
+	DataSources             []dataSource            `json:"dataSource,omitempty"`
+	PosterImages            []posterImage           `json:"posterImages,omitempty"`
+	Audio                   *audioRendition         `json:"audio,omitempty"`
+	Duration                *float64                `json:"duration,omitempty"`
+	ISODuration             string                  `json:"isoDuration,omitempty"`
+	CanBeDistributed        string                  `json:"canBeDistributed,omitempty"`
+	Type                    string                  `json:"type,omitempty"`
+	LastModified            string                  `json:"lastModified,omitempty"`
+	PublishReference        string                  `json:"publishReference,omitempty"`
+	CanBeSyndicated         string                  `json:"canBeSyndicated,omitempty"`
+	AccessLevel             string                  `json:"accessLevel,omitempty"`
+	WebURL                  string                  `json:"webUrl,omitempty"`
+	CanonicalWebURL         string                  `json:"canonicalWebUrl,omitempty"`
+	AlternativeTitles       *alternativeTitles      `json:"alternativeTitles,omitempty"`
+	AlternativeStandfirst   *alternativeStandfirsts `json:"alternativeStandfirsts,omitempty"`
+	Deleted                 bool                    `json:"deleted,omitempty"`
 }
 
 type caption struct {
 	Url       string `json:"url"`
 	MediaType string `json:"mediaType"`
+	Format    string `json:"format,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Default   bool   `json:"default,omitempty"`
+}
+
+// posterImage is a poster frame or thumbnail taken from the encoding outputs.
+type posterImage struct {
+	Url         string   `json:"url"`
+	MediaType   string   `json:"mediaType,omitempty"`
+	PixelWidth  *float64 `json:"pixelWidth,omitempty"`
+	PixelHeight *float64 `json:"pixelHeight,omitempty"`
+}
+
+// audioRendition describes the audio of audio-only content.
+type audioRendition struct {
+	BinaryUrl  string   `json:"binaryUrl"`
+	MediaType  string   `json:"mediaType,omitempty"`
+	Duration   *float64 `json:"duration,omitempty"`
+	AudioCodec string   `json:"audioCodec,omitempty"`
+	Bitrate    *float64 `json:"bitrate,omitempty"`
+}
+
+// transcriptSegment is a time-coded part of the transcript, start and end being in milliseconds.
+type transcriptSegment struct {
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
 }
 
+// dataSource is a rendition or a streaming manifest of the video. AspectRatio, Orientation, AudioOnly and Streaming
+// are derived from the other fields.
 type dataSource struct {
 	BinaryUrl   string   `json:"binaryUrl,omitempty"`
 	PixelWidth  *float64 `json:"pixelWidth,omitempty"`
@@ -66,4 +126,12 @@ type dataSource struct {
 	Duration    *float64 `json:"duration,omitempty"`
 	VideoCodec  string   `json:"videoCodec,omitempty"`
 	AudioCodec  string   `json:"audioCodec,omitempty"`
+	Bitrate     *float64 `json:"bitrate,omitempty"`
+	FileSize    *float64 `json:"fileSize,omitempty"`
+	FrameRate   *float64 `json:"frameRate,omitempty"`
+	Container   string   `json:"container,omitempty"`
+	AspectRatio *float64 `json:"aspectRatio,omitempty"`
+	Orientation string   `json:"orientation,omitempty"`
+	AudioOnly   bool     `json:"audioOnly,omitempty"`
+	Streaming   bool     `json:"streaming,omitempty"`
 }
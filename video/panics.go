@@ -0,0 +1,111 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/kafka-client-go/v4"
+)
+
+const deadLetterReasonHeader = "X-Dead-Letter-Reason"
+
+// PanicTracker remembers when messages made the handler panic, so the health check
+// can turn red when they keep coming.
+type PanicTracker struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	times     []time.Time
+	now       func() time.Time
+}
+
+// NewPanicTracker reports more than threshold panics within window as unhealthy.
+func NewPanicTracker(threshold int, window time.Duration) *PanicTracker {
+	return &PanicTracker{
+		threshold: threshold,
+		window:    window,
+		now:       time.Now,
+	}
+}
+
+func (p *PanicTracker) record() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.times = append(p.recent(), p.now())
+}
+
+// Check returns an error when the panics within the window are over the threshold.
+func (p *PanicTracker) Check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.times = p.recent()
+	if len(p.times) > p.threshold {
+		return fmt.Errorf("%d messages made the mapping panic in the last %v, over the threshold of %d", len(p.times), p.window, p.threshold)
+	}
+	return nil
+}
+
+func (p *PanicTracker) recent() []time.Time {
+	since := p.now().Add(-p.window)
+	i := 0
+	for i < len(p.times) && p.times[i].Before(since) {
+		i++
+	}
+	return p.times[i:]
+}
+
+// handlePanic logs the panic of a message with its stack, then sends the message to the dead letter
+// producer when there is one. Otherwise the message is skipped.
+func (v *VideoMapperHandler) handlePanic(m kafka.FTMessage, r interface{}) error {
+	transactionID := m.Headers["X-Request-Id"]
+	contentUUID := nativeUUID(m.Body)
+	err := fmt.Errorf("panic while handling message: %v", r)
+
+	v.log.WithTransactionID(transactionID).
+		WithUUID(contentUUID).
+		WithError(err).
+		WithField("stack", string(debug.Stack())).
+		Error("Recovered from a panic, the message is not mapped")
+	v.metrics.MessagePanicked()
+	v.metrics.MessageFailed(errorClassPanic)
+	if v.panics != nil {
+		v.panics.record()
+	}
+
+	if v.deadLetterProducer == nil {
+		return err
+	}
+	headers := make(map[string]string, len(m.Headers)+1)
+	for k, val := range m.Headers {
+		headers[k] = val
+	}
+	headers[deadLetterReasonHeader] = err.Error()
+	if sendErr := v.deadLetterProducer.SendMessage(kafka.FTMessage{Headers: headers, Body: m.Body}); sendErr != nil {
+		v.log.WithTransactionID(transactionID).
+			WithUUID(contentUUID).
+			WithError(sendErr).
+			Error("Couldn't send the message to the dead letter queue")
+	}
+	return err
+}
+
+// nativeUUID returns the UUID of a native video without mapping it, or an empty string.
+func nativeUUID(body string) string {
+	var native struct {
+		ID   interface{} `json:"id"`
+		UUID interface{} `json:"uuid"`
+	}
+	if err := json.Unmarshal([]byte(body), &native); err != nil {
+		return ""
+	}
+	if id, ok := native.ID.(string); ok {
+		return id
+	}
+	if id, ok := native.UUID.(string); ok {
+		return id
+	}
+	return ""
+}
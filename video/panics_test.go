@@ -0,0 +1,30 @@
+package video
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanicTracker_Window(t *testing.T) {
+	now := time.Date(2017, 4, 13, 10, 0, 0, 0, time.UTC)
+	panics := NewPanicTracker(1, 10*time.Minute)
+	panics.now = func() time.Time { return now }
+
+	assert.NoError(t, panics.Check())
+	panics.record()
+	now = now.Add(5 * time.Minute)
+	panics.record()
+	assert.EqualError(t, panics.Check(), "2 messages made the mapping panic in the last 10m0s, over the threshold of 1")
+
+	now = now.Add(6 * time.Minute)
+	assert.NoError(t, panics.Check(), "Panics older than the window should be forgotten")
+}
+
+func TestNativeUUID(t *testing.T) {
+	assert.Equal(t, "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", nativeUUID(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`))
+	assert.Equal(t, "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", nativeUUID(`{"uuid": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "deleted": true}`))
+	assert.Empty(t, nativeUUID(`{"id": 42}`))
+	assert.Empty(t, nativeUUID(`not json`))
+}
@@ -0,0 +1,165 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	SeverityBlock = "block"
+	SeverityWarn  = "warn"
+	SeverityStrip = "strip-field"
+
+	RuleTitleRequired    = "title-required"
+	RuleMP4Required      = "mp4-required"
+	RuleCaptionsRequired = "captions-required"
+	RuleMainImageValid   = "main-image-valid"
+
+	warningPolicyBlocked  = "policy_blocked"
+	warningPolicyViolated = "policy_violated"
+	warningPolicyStripped = "policy_stripped"
+)
+
+// Policy holds the publication rules checked on every mapped video.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a publication rule with the severity applied when a video breaks it.
+// MinDurationSeconds is only used by the captions-required rule.
+type PolicyRule struct {
+	Rule               string  `json:"rule"`
+	Severity           string  `json:"severity"`
+	MinDurationSeconds float64 `json:"minDurationSeconds,omitempty"`
+}
+
+// policyCheck checks a rule on the payload. strip removes what breaks the rule, and is nil
+// for the rules broken by a missing field, where there is nothing to remove.
+type policyCheck struct {
+	field string
+	check func(p *videoPayload, r PolicyRule) (string, bool)
+	strip func(p *videoPayload)
+}
+
+var policyChecks = map[string]policyCheck{
+	RuleTitleRequired: {
+		field: "title",
+		check: func(p *videoPayload, _ PolicyRule) (string, bool) {
+			return "the title is missing", strings.TrimSpace(p.Title) != ""
+		},
+	},
+	RuleMP4Required: {
+		field: "dataSource",
+		check: func(p *videoPayload, _ PolicyRule) (string, bool) {
+			for _, d := range p.DataSources {
+				if d.MediaType == "video/mp4" && d.BinaryUrl != "" {
+					return "", true
+				}
+			}
+			return "there is no MP4 data source", false
+		},
+		// the video renditions in other formats go, the audio renditions and streaming manifests stay
+		strip: func(p *videoPayload) {
+			var kept []dataSource
+			for _, d := range p.DataSources {
+				if d.Streaming || d.AudioOnly || d.MediaType == "video/mp4" {
+					kept = append(kept, d)
+				}
+			}
+			p.DataSources = kept
+		},
+	},
+	RuleCaptionsRequired: {
+		field: "captions",
+		check: func(p *videoPayload, r PolicyRule) (string, bool) {
+			duration := maxDuration(p.DataSources) / 1000
+			if p.Duration != nil {
+				duration = *p.Duration / 1000
+			}
+			if duration <= r.MinDurationSeconds || len(p.Captions) > 0 {
+				return "", true
+			}
+			return fmt.Sprintf("captions are missing for a video of %.0f seconds, longer than %.0f seconds", duration, r.MinDurationSeconds), false
+		},
+	},
+	RuleMainImageValid: {
+		field: "mainImage",
+		check: func(p *videoPayload, _ PolicyRule) (string, bool) {
+			return "there is no valid main image", p.MainImage != ""
+		},
+	},
+}
+
+// LoadPolicy reads and validates the JSON publication policy at path.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, fmt.Errorf("couldn't read publication policy: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Policy{}, fmt.Errorf("invalid publication policy: %w", err)
+	}
+	return p, p.Validate()
+}
+
+func (p Policy) Validate() error {
+	for _, r := range p.Rules {
+		pc, ok := policyChecks[r.Rule]
+		if !ok {
+			return fmt.Errorf("unknown publication rule %q", r.Rule)
+		}
+		switch r.Severity {
+		case SeverityBlock, SeverityWarn:
+		case SeverityStrip:
+			if pc.strip == nil {
+				return fmt.Errorf("severity %q has nothing to remove for publication rule %q", r.Severity, r.Rule)
+			}
+		default:
+			return fmt.Errorf("unknown severity %q for publication rule %q", r.Severity, r.Rule)
+		}
+	}
+	return nil
+}
+
+// applyPolicy checks the payload against the rules of the policy, adding a warning for every broken rule.
+// The fields derived from the data sources are computed again when a rule strips some of them.
+// It returns the reasons the video must not be published, if any.
+func (v VideoMapper) applyPolicy(videoContent map[string]interface{}, payload *videoPayload, report *mappingReport) []string {
+	var blocked []string
+	for _, r := range v.policy.Rules {
+		pc := policyChecks[r.Rule]
+		reason, ok := pc.check(payload, r)
+		if ok {
+			continue
+		}
+
+		message := fmt.Sprintf("Publication rule %s: %s", r.Rule, reason)
+		switch {
+		case r.Severity == SeverityBlock:
+			blocked = append(blocked, message)
+			v.warn(report, pc.field, newFieldError(warningPolicyBlocked, "%s", message), "%s", message)
+		case r.Severity == SeverityStrip && pc.strip != nil:
+			pc.strip(payload)
+			// the duration warnings were raised on the original data sources already
+			_ = v.setRenditionFields(videoContent, payload)
+			v.warn(report, pc.field, newFieldError(warningPolicyStripped, "%s", message), "%s", message)
+		default:
+			v.warn(report, pc.field, newFieldError(warningPolicyViolated, "%s", message), "%s", message)
+		}
+	}
+	return blocked
+}
+
+func maxDuration(dataSources []dataSource) float64 {
+	var longest float64
+	for _, d := range dataSources {
+		if d.Duration != nil && *d.Duration > longest {
+			longest = *d.Duration
+		}
+	}
+	return longest
+}
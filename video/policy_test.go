@@ -0,0 +1,156 @@
+package video
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const policyTestBody = `{
+	"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+	"title": "ECB and Fed debates hit dollar and euro",
+	"image": "77d86b7c-dfed-4a9b-a4b6-156d4afefd8c",
+	"canBeSyndicated": true,
+	"related": [],
+	"transcription": {"transcript": "<p>Transcript</p>"},
+	"encoding": {"outputs": [{"duration": 68587, "mediaType": "video/mp4", "url": "http://ftvideo.example.com/640x360.mp4"}]}
+}`
+
+func policyTestMessage(body string) kafka.FTMessage {
+	return kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: body,
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	assert.NoError(t, Policy{Rules: []PolicyRule{{Rule: RuleTitleRequired, Severity: SeverityBlock}}}.Validate())
+	assert.EqualError(t, Policy{Rules: []PolicyRule{{Rule: "unknown", Severity: SeverityBlock}}}.Validate(), `unknown publication rule "unknown"`)
+	assert.EqualError(t, Policy{Rules: []PolicyRule{{Rule: RuleTitleRequired, Severity: "fatal"}}}.Validate(), `unknown severity "fatal" for publication rule "title-required"`)
+	assert.NoError(t, Policy{Rules: []PolicyRule{{Rule: RuleMP4Required, Severity: SeverityStrip}}}.Validate())
+	for _, rule := range []string{RuleTitleRequired, RuleCaptionsRequired, RuleMainImageValid} {
+		assert.EqualError(t, Policy{Rules: []PolicyRule{{Rule: rule, Severity: SeverityStrip}}}.Validate(),
+			`severity "strip-field" has nothing to remove for publication rule "`+rule+`"`)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"rule": "captions-required", "severity": "warn", "minDurationSeconds": 60}]}`), 0600))
+
+	p, err := LoadPolicy(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, Policy{Rules: []PolicyRule{{Rule: RuleCaptionsRequired, Severity: SeverityWarn, MinDurationSeconds: 60}}}, p)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"rule": "captions-required", "severity": "ignore"}]}`), 0600))
+	_, err = LoadPolicy(path)
+	assert.Error(t, err)
+}
+
+func TestPolicy_Block(t *testing.T) {
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleTitleRequired, Severity: SeverityBlock},
+		{Rule: RuleMP4Required, Severity: SeverityBlock},
+	}}))
+
+	msg, uuid, warnings, err := m.TransformMsg(policyTestMessage(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "canBeSyndicated": true}`))
+
+	assert.Equal(t, errorClassBlocked, errorClass(err))
+	assert.Empty(t, msg.Body)
+	assert.Equal(t, "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", uuid)
+	assert.Contains(t, warnings, MappingWarning{Field: "title", Code: warningPolicyBlocked, Message: "Publication rule title-required: the title is missing"})
+	assert.Contains(t, warnings, MappingWarning{Field: "dataSource", Code: warningPolicyBlocked, Message: "Publication rule mp4-required: there is no MP4 data source"})
+}
+
+func TestPolicy_Warn(t *testing.T) {
+	metrics := &mockMetrics{skipped: map[string]int{}, failed: map[string]int{}, warnings: map[string]int{}}
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithMapperMetrics(metrics), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleTitleRequired, Severity: SeverityBlock},
+		{Rule: RuleCaptionsRequired, Severity: SeverityWarn, MinDurationSeconds: 60},
+	}}))
+
+	msg, _, warnings, err := m.TransformMsg(policyTestMessage(policyTestBody))
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.Body)
+	assert.Equal(t, []MappingWarning{
+		{Field: "captions", Code: warningPolicyViolated, Message: "Publication rule captions-required: captions are missing for a video of 69 seconds, longer than 60 seconds"},
+	}, warnings)
+	assert.Equal(t, map[string]int{"captions": 1}, metrics.warnings, "Policy warnings should be counted like the other warnings")
+}
+
+func TestPolicy_CaptionsNotRequiredForShortVideos(t *testing.T) {
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleCaptionsRequired, Severity: SeverityBlock, MinDurationSeconds: 120},
+	}}))
+
+	_, _, warnings, err := m.TransformMsg(policyTestMessage(policyTestBody))
+
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestPolicy_StripField(t *testing.T) {
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleMP4Required, Severity: SeverityStrip},
+	}}))
+	body := `{
+		"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+		"image": "77d86b7c-dfed-4a9b-a4b6-156d4afefd8c",
+		"canBeSyndicated": true,
+		"related": [],
+		"transcription": {"transcript": "<p>Transcript</p>"},
+		"encoding": {"outputs": [
+			{"duration": 68544, "mediaType": "application/x-mpegURL", "url": "http://ftvideo.example.com/master.m3u8"},
+			{"duration": 68544, "mediaType": "video/webm", "width": 640, "height": 360, "url": "http://ftvideo.example.com/640x360.webm"},
+			{"duration": 68544, "mediaType": "audio/mpeg", "url": "http://ftvideo.example.com/0x0.mp3"}
+		]}
+	}`
+
+	msg, _, warnings, err := m.TransformMsg(policyTestMessage(body))
+
+	require.NoError(t, err)
+	assert.Equal(t, []MappingWarning{
+		{Field: "dataSource", Code: warningPolicyStripped, Message: "Publication rule mp4-required: there is no MP4 data source"},
+	}, warnings)
+	assert.NotContains(t, msg.Body, "640x360.webm", "The video renditions breaking the rule should be removed")
+	assert.Contains(t, msg.Body, "master.m3u8", "The streaming manifests should be kept")
+	assert.Contains(t, msg.Body, "0x0.mp3", "The audio renditions should be kept")
+}
+
+func TestPolicy_StripFieldRecomputesDerivedFields(t *testing.T) {
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleMP4Required, Severity: SeverityStrip},
+	}}))
+	body := `{
+		"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+		"title": "Title",
+		"encoding": {"outputs": [
+			{"duration": 68544, "mediaType": "video/webm", "width": 640, "height": 360, "url": "http://ftvideo.example.com/640x360.webm"},
+			{"duration": 61000, "mediaType": "audio/mpeg", "audioCodec": "mp3", "url": "http://ftvideo.example.com/0x0.mp3"}
+		]}
+	}`
+
+	msg, _, _, err := m.TransformMsg(policyTestMessage(body))
+
+	require.NoError(t, err)
+	var event publicationEvent
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	payload := event.Payload
+	assert.Equal(t, DefaultTypeMapping.Audio, payload.Type, "Only the audio rendition is left after the strip")
+	require.NotNil(t, payload.Audio)
+	assert.Equal(t, "http://ftvideo.example.com/0x0.mp3", payload.Audio.BinaryUrl)
+	require.NotNil(t, payload.Duration)
+	assert.Equal(t, 61000.0, *payload.Duration, "The duration should come from the renditions left")
+	assert.Equal(t, "PT1M1S", payload.ISODuration)
+}
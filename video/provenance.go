@@ -0,0 +1,69 @@
+package video
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	ruleCopy             = "copy"
+	ruleHeader           = "header"
+	ruleDate             = "date"
+	ruleNow              = "now"
+	ruleConstant         = "constant"
+	ruleIdentifier       = "identifier"
+	ruleUUID             = "uuid"
+	ruleStoryPackage     = "story-package-uuid"
+	ruleBodyXML          = "body-xml"
+	ruleWebVTT           = "webvtt-captions"
+	ruleCaptions         = "captions"
+	ruleSegments         = "segments"
+	ruleEncodingOutputs  = "encoding-outputs"
+	rulePosterFrame      = "poster-frame"
+	ruleContentType      = "content-type"
+	rulePrimaryRendition = "primary-rendition"
+	ruleYesNo            = "yes-no"
+	ruleURLTemplate      = "url-template"
+)
+
+// FieldProvenance tells where a field of the mapped payload came from.
+// Source is the JSON path in the native video, or the message header, the value was read from.
+type FieldProvenance struct {
+	Field     string `json:"field"`
+	Source    string `json:"source,omitempty"`
+	Rule      string `json:"rule"`
+	Defaulted bool   `json:"defaulted"`
+}
+
+// MappingExplanation is the mapped message together with the provenance of its payload fields.
+// Blocked holds the reasons the publication policy blocks the message, which is then not sent.
+type MappingExplanation struct {
+	UUID     string            `json:"uuid"`
+	Message  json.RawMessage   `json:"message"`
+	Fields   []FieldProvenance `json:"fields"`
+	Warnings []MappingWarning  `json:"warnings,omitempty"`
+	Blocked  []string          `json:"blocked,omitempty"`
+}
+
+// presentFields keeps the provenance of the fields which made it into the payload.
+func presentFields(fields []FieldProvenance, payload map[string]interface{}) []FieldProvenance {
+	present := []FieldProvenance{}
+	for _, f := range fields {
+		if hasPath(payload, strings.Split(f.Field, ".")) {
+			present = append(present, f)
+		}
+	}
+	return present
+}
+
+func hasPath(m map[string]interface{}, path []string) bool {
+	value, ok := m[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) == 1 {
+		return true
+	}
+	nested, ok := value.(map[string]interface{})
+	return ok && hasPath(nested, path[1:])
+}
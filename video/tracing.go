@@ -0,0 +1,25 @@
+package video
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Financial-Times/upp-next-video-mapper/video"
+
+var (
+	attrTransactionID = attribute.Key("ft.transaction_id")
+	attrContentUUID   = attribute.Key("ft.content.uuid")
+	attrOrigin        = attribute.Key("ft.origin_system_id")
+	attrSkipReason    = attribute.Key("ft.skip_reason")
+)
+
+// endSpan marks the span as failed when err is set and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
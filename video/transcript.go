@@ -0,0 +1,142 @@
+package video
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+const defaultParagraphGap = 2 * time.Second
+
+var transcriptEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// getInlineWebVTT returns the content of the subtitles or captions track carrying its WebVTT document inline,
+// the default track first. Tracks without a kind count as subtitles; descriptions and chapters are not transcripts.
+func getInlineWebVTT(transcriptionMap map[string]interface{}) (string, bool) {
+	captions, _ := transcriptionMap["captions"].([]interface{})
+	var first string
+	found := false
+	for _, elem := range captions {
+		captionMap, ok := elem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _ := get("kind", captionMap)
+		switch strings.ToLower(kind) {
+		case "", "subtitles", "captions":
+		default:
+			continue
+		}
+		content, err := get("content", captionMap)
+		if err != nil || !strings.HasPrefix(strings.TrimPrefix(content, "\ufeff"), "WEBVTT") {
+			continue
+		}
+		if isDefault, _ := getBool("default", captionMap); isDefault {
+			return content, true
+		}
+		if !found {
+			first, found = content, true
+		}
+	}
+	return first, found
+}
+
+// cuesTranscript merges the cues into bodyXML paragraphs, starting a new paragraph
+// whenever the silence between two cues is at least gap.
+func cuesTranscript(cues []utils.Cue, gap time.Duration) string {
+	var paragraphs []string
+	var current []string
+	var lastEnd time.Duration
+	for _, cue := range cues {
+		if cue.Text == "" {
+			continue
+		}
+		if len(current) > 0 && cue.Start-lastEnd >= gap {
+			paragraphs = append(paragraphs, strings.Join(current, " "))
+			current = nil
+		}
+		current = append(current, transcriptEscaper.Replace(cue.Text))
+		lastEnd = cue.End
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, " "))
+	}
+	if len(paragraphs) == 0 {
+		return ""
+	}
+	return "<body><p>" + strings.Join(paragraphs, "</p><p>") + "</p></body>"
+}
+
+// getTranscriptSegments returns the native transcript segments, or the segments made of the caption cues
+// when the native video has none. Native segments without a valid time range or text are skipped.
+func getTranscriptSegments(transcriptionMap map[string]interface{}, cues []utils.Cue) ([]transcriptSegment, bool, error) {
+	native, ok := transcriptionMap["segments"]
+	if !ok {
+		return cuesSegments(cues), len(cues) > 0, nil
+	}
+
+	nativeSegments, ok := native.([]interface{})
+	if !ok {
+		return cuesSegments(cues), len(cues) > 0, newFieldError(warningWrongType, "[segments] field of native video JSON is not an array")
+	}
+
+	var segments []transcriptSegment
+	skipped := 0
+	for _, elem := range nativeSegments {
+		segment, ok := getTranscriptSegment(elem)
+		if !ok {
+			skipped++
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	if skipped > 0 {
+		return segments, false, newFieldError(warningMalformed, "Skipped %d of %d transcript segments without a valid time range or text", skipped, len(nativeSegments))
+	}
+	return segments, false, nil
+}
+
+func getTranscriptSegment(elem interface{}) (transcriptSegment, bool) {
+	segmentMap, ok := elem.(map[string]interface{})
+	if !ok {
+		return transcriptSegment{}, false
+	}
+	// the times are bounded like the durations of the renditions, so that they fit in milliseconds
+	start, err := getNumber("start", segmentMap)
+	if err != nil || !durationRange.contains(*start) {
+		return transcriptSegment{}, false
+	}
+	end, err := getNumber("end", segmentMap)
+	if err != nil || !durationRange.contains(*end) || *end < *start {
+		return transcriptSegment{}, false
+	}
+	text, err := get("text", segmentMap)
+	if err != nil || strings.TrimSpace(text) == "" {
+		return transcriptSegment{}, false
+	}
+	speaker, _ := get("speaker", segmentMap)
+
+	return transcriptSegment{
+		Start:   int64(*start),
+		End:     int64(*end),
+		Speaker: speaker,
+		Text:    text,
+	}, true
+}
+
+func cuesSegments(cues []utils.Cue) []transcriptSegment {
+	var segments []transcriptSegment
+	for _, cue := range cues {
+		if cue.Text == "" {
+			continue
+		}
+		segments = append(segments, transcriptSegment{
+			Start:   cue.Start.Milliseconds(),
+			End:     cue.End.Milliseconds(),
+			Speaker: cue.Voice,
+			Text:    cue.Text,
+		})
+	}
+	return segments
+}
@@ -0,0 +1,167 @@
+package video
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCuesTranscript(t *testing.T) {
+	cues := []utils.Cue{
+		{Start: 0, End: time.Second, Text: "From the FT in London,"},
+		{Start: time.Second, End: 2 * time.Second, Text: "here's the latest."},
+		{Start: 4 * time.Second, End: 5 * time.Second, Text: "Markets & more"},
+		{Start: 5 * time.Second, End: 6 * time.Second},
+	}
+
+	assert.Equal(t, "<body><p>From the FT in London, here's the latest.</p><p>Markets &amp; more</p></body>", cuesTranscript(cues, 2*time.Second))
+	assert.Equal(t, "<body><p>From the FT in London, here's the latest. Markets &amp; more</p></body>", cuesTranscript(cues, 3*time.Second))
+	assert.Empty(t, cuesTranscript(nil, time.Second))
+}
+
+func TestGetInlineWebVTT(t *testing.T) {
+	track := func(kind string, isDefault bool, content string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "default": isDefault, "content": "WEBVTT\n\n00:00.000 --> 00:01.000\n" + content}
+	}
+	tests := []struct {
+		name     string
+		captions []interface{}
+		expected string
+	}{
+		{
+			name:     "default track",
+			captions: []interface{}{track("subtitles", false, "French"), track("captions", true, "English")},
+			expected: "English",
+		},
+		{
+			name:     "first subtitles track",
+			captions: []interface{}{track("descriptions", true, "Described"), track("chapters", false, "Chapter"), track("", false, "English"), track("subtitles", false, "French")},
+			expected: "English",
+		},
+		{
+			name:     "no subtitles track",
+			captions: []interface{}{track("descriptions", true, "Described"), track("chapters", false, "Chapter")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, ok := getInlineWebVTT(map[string]interface{}{"captions": test.captions})
+
+			if test.expected == "" {
+				assert.False(t, ok)
+			} else if assert.True(t, ok) {
+				assert.True(t, strings.HasSuffix(content, test.expected), content)
+			}
+		})
+	}
+}
+
+func TestTransformMsg_TranscriptFromCaptions(t *testing.T) {
+	vtt := "WEBVTT\n\n00:00.000 --> 00:01.000\nFrom the FT in London,\n\n00:01.500 --> 00:02.000\nhere's the latest.\n\n00:03.000 --> 00:04.000\n<v Katie Martin>Markets</v>\n"
+	body, err := json.Marshal(map[string]interface{}{
+		"id":              "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+		"canBeSyndicated": true,
+		"related":         []interface{}{},
+		"transcription": map[string]interface{}{
+			"captions": []interface{}{
+				map[string]interface{}{"format": "vtt", "mediaType": "text/vtt", "url": "https://next-video-editor.ft.com/783739.vtt", "content": vtt},
+			},
+		},
+	})
+	require.NoError(t, err)
+	message := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: string(body),
+	}
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithParagraphGap(time.Second))
+
+	msg, _, warnings, err := m.TransformMsg(message)
+
+	require.NoError(t, err)
+	var event publicationEvent
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	assert.Equal(t, "<body><p>From the FT in London, here's the latest.</p><p>Markets</p></body>", event.Payload.Transcript)
+	assert.True(t, event.Payload.TranscriptAutoGenerated)
+	assert.Equal(t, []transcriptSegment{
+		{Start: 0, End: 1000, Text: "From the FT in London,"},
+		{Start: 1500, End: 2000, Text: "here's the latest."},
+		{Start: 3000, End: 4000, Speaker: "Katie Martin", Text: "Markets"},
+	}, event.Payload.TranscriptSegments)
+	for _, w := range warnings {
+		assert.NotEqual(t, "transcript", w.Field)
+	}
+}
+
+func TestTransformMsg_MalformedCaptionsTranscript(t *testing.T) {
+	message := kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{
+			"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+			"transcription": {"captions": [{"url": "https://next-video-editor.ft.com/783739.vtt", "content": "WEBVTT\n\n00:00 --> 00:01.000\nText"}]}
+		}`,
+	}
+
+	msg, _, warnings, err := mapper.TransformMsg(message)
+
+	require.NoError(t, err)
+	assert.NotContains(t, msg.Body, "transcriptAutoGenerated")
+	assert.Contains(t, warnings, MappingWarning{Field: "transcript", Code: warningMalformed, Message: `Couldn't generate a transcript from the WebVTT captions: invalid cue timing on line 3: invalid timestamp "00:00"`})
+	assert.Contains(t, warnings, MappingWarning{Field: "transcript", Code: warningMissing, Message: "[transcript] field of native video JSON is null"})
+}
+
+func TestGetTranscriptSegments(t *testing.T) {
+	cues := []utils.Cue{{Start: 0, End: time.Second, Text: "From the captions"}}
+	var transcription map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"segments": [
+			{"start": 0, "end": 1200, "speaker": "Katie Martin", "text": "From the FT in London,"},
+			{"start": 1200, "end": 2500, "text": "here's the latest."},
+			{"start": 3000, "end": 2000, "text": "ends before it starts"},
+			{"start": 3000, "end": 4000},
+			{"start": 3000, "end": 1e300, "text": "ends after int64 overflows"},
+			{"start": -1e300, "end": 4000, "text": "starts before zero"},
+			"not a segment"
+		]
+	}`), &transcription))
+
+	segments, fromCues, err := getTranscriptSegments(transcription, cues)
+
+	assert.Equal(t, []transcriptSegment{
+		{Start: 0, End: 1200, Speaker: "Katie Martin", Text: "From the FT in London,"},
+		{Start: 1200, End: 2500, Text: "here's the latest."},
+	}, segments)
+	assert.False(t, fromCues)
+	assert.EqualError(t, err, "Skipped 5 of 7 transcript segments without a valid time range or text")
+	assert.Equal(t, warningMalformed, warningCode(err))
+
+	_, ok := getTranscriptSegment(map[string]interface{}{"start": math.NaN(), "end": 4000.0, "text": "NaN start"})
+	assert.False(t, ok, "Segments starting at NaN should be skipped")
+	_, ok = getTranscriptSegment(map[string]interface{}{"start": 0.0, "end": math.Inf(1), "text": "endless"})
+	assert.False(t, ok, "Segments ending at infinity should be skipped")
+
+	segments, fromCues, err = getTranscriptSegments(map[string]interface{}{}, cues)
+
+	assert.NoError(t, err)
+	assert.True(t, fromCues)
+	assert.Equal(t, []transcriptSegment{{Start: 0, End: 1000, Text: "From the captions"}}, segments)
+
+	segments, _, err = getTranscriptSegments(nil, nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, segments)
+}
@@ -1,8 +1,11 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"regexp"
@@ -29,30 +32,193 @@ const (
 
 var uuidExtractRegex = regexp.MustCompile(".*/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$")
 
+const (
+	errorClassMissingTransactionID = "missing_transaction_id"
+	errorClassInvalidJSON          = "invalid_json"
+	errorClassMissingUUID          = "missing_uuid"
+	errorClassMarshal              = "marshal"
+	errorClassCancelled            = "cancelled"
+	errorClassBlocked              = "blocked"
+	errorClassInvalidDate          = "invalid_date"
+	errorClassUnknown              = "unknown"
+)
+
+// mappingError classifies why a message couldn't be mapped, keeping the original error message.
+type mappingError struct {
+	class string
+	err   error
+}
+
+func (e *mappingError) Error() string {
+	return e.err.Error()
+}
+
+func (e *mappingError) Unwrap() error {
+	return e.err
+}
+
+// Rejected tells whether the message itself is at fault: it is invalid or blocked from publication,
+// and would fail the same way if it was handled again.
+func (e *mappingError) Rejected() bool {
+	switch e.class {
+	case errorClassMissingTransactionID, errorClassInvalidJSON, errorClassMissingUUID, errorClassBlocked, errorClassInvalidDate:
+		return true
+	default:
+		return false
+	}
+}
+
+func errorClass(err error) string {
+	var me *mappingError
+	if errors.As(err, &me) {
+		return me.class
+	}
+	return errorClassUnknown
+}
+
 type VideoMapper struct {
-	log *logger.UPPLogger
+	log               *logger.UPPLogger
+	metrics           mappingMetrics
+	policy            Policy
+	paragraphGap      time.Duration
+	types             TypeMapping
+	durationTolerance time.Duration
+	dateValidation    DateValidation
+	clock             func() time.Time
+	messageID         func() string
+}
+
+type MapperOption func(*VideoMapper)
+
+// WithMapperMetrics counts the mapping warnings by output field.
+func WithMapperMetrics(m mappingMetrics) MapperOption {
+	return func(v *VideoMapper) {
+		v.metrics = m
+	}
+}
+
+// WithPolicy checks every mapped video against the publication rules of p.
+func WithPolicy(p Policy) MapperOption {
+	return func(v *VideoMapper) {
+		v.policy = p
+	}
+}
+
+// WithParagraphGap sets the silence between two caption cues that starts a new paragraph
+// in the transcripts generated from captions.
+func WithParagraphGap(gap time.Duration) MapperOption {
+	return func(v *VideoMapper) {
+		v.paragraphGap = gap
+	}
+}
+
+// WithDurationTolerance sets how much the duration of a rendition may differ from the one
+// of the primary rendition before a warning is raised.
+func WithDurationTolerance(tolerance time.Duration) MapperOption {
+	return func(v *VideoMapper) {
+		v.durationTolerance = tolerance
+	}
+}
+
+// WithClock replaces the current time used when the Message-Timestamp header is missing or
+// unparseable, and to find the dates in the future.
+func WithClock(clock func() time.Time) MapperOption {
+	return func(v *VideoMapper) {
+		v.clock = clock
+	}
+}
+
+// WithMessageIDGenerator replaces the random UUIDs used as Message-Id of the mapped messages.
+func WithMessageIDGenerator(generate func() string) MapperOption {
+	return func(v *VideoMapper) {
+		v.messageID = generate
+	}
 }
 
-func NewVideoMapper(log *logger.UPPLogger) VideoMapper {
-	return VideoMapper{
-		log: log,
+func NewVideoMapper(log *logger.UPPLogger, opts ...MapperOption) VideoMapper {
+	v := VideoMapper{
+		log:               log,
+		metrics:           noopMetrics{},
+		paragraphGap:      defaultParagraphGap,
+		types:             DefaultTypeMapping,
+		durationTolerance: defaultDurationTolerance,
+	}
+	for _, opt := range opts {
+		opt(&v)
 	}
+	return v
 }
 
-func (v VideoMapper) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, error) {
-	tid := m.Headers["X-Request-Id"]
+func (v VideoMapper) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, []MappingWarning, error) {
+	return v.TransformMsgContext(context.Background(), m)
+}
+
+// TransformMsgContext maps the native video in m, giving up as soon as ctx is done.
+// Besides the mapped message and the content UUID it returns the problems found in the native video.
+func (v VideoMapper) TransformMsgContext(ctx context.Context, m kafka.FTMessage) (kafka.FTMessage, string, []MappingWarning, error) {
+	report := newMappingReport(m.Headers["X-Request-Id"], false)
+	message, uuid, err := v.transform(ctx, m, report)
+	if errorClass(err) == errorClassBlocked {
+		return kafka.FTMessage{}, uuid, report.warnings, err
+	}
+	if err != nil {
+		return kafka.FTMessage{}, uuid, nil, err
+	}
+	return message, uuid, report.warnings, nil
+}
+
+// ExplainMsg maps the native video in m like TransformMsgContext,
+// and also tells where every field of the mapped payload came from.
+func (v VideoMapper) ExplainMsg(ctx context.Context, m kafka.FTMessage) (MappingExplanation, error) {
+	report := newMappingReport(m.Headers["X-Request-Id"], true)
+	message, uuid, err := v.transform(ctx, m, report)
+	// a blocked video is explained too, since why it is blocked is what the caller wants to know
+	if err != nil && errorClass(err) != errorClassBlocked {
+		return MappingExplanation{}, err
+	}
+	blockedErr := err
+
+	var event struct {
+		Payload map[string]interface{} `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(message.Body), &event); err != nil {
+		return MappingExplanation{}, &mappingError{errorClassMarshal, err}
+	}
+
+	return MappingExplanation{
+		UUID:     uuid,
+		Message:  json.RawMessage(message.Body),
+		Fields:   presentFields(report.provenance, event.Payload),
+		Warnings: report.warnings,
+		Blocked:  report.blocked,
+	}, blockedErr
+}
+
+func (v VideoMapper) transform(ctx context.Context, m kafka.FTMessage, report *mappingReport) (kafka.FTMessage, string, error) {
+	if err := ctx.Err(); err != nil {
+		return kafka.FTMessage{}, "", &mappingError{errorClassCancelled, err}
+	}
+
+	tid := report.tid
 	if tid == "" {
-		return kafka.FTMessage{}, "", fmt.Errorf("header X-Request-Id not found in kafka message headers. Skipping message")
+		return kafka.FTMessage{}, "", &mappingError{errorClassMissingTransactionID, fmt.Errorf("header X-Request-Id not found in kafka message headers. Skipping message")}
 	}
 
 	lastModified := m.Headers["Message-Timestamp"]
 	if lastModified == "" {
-		lastModified = time.Now().Format(dateFormat)
+		lastModified = v.now().UTC().Format(dateFormat)
+		report.record("lastModified", "", ruleNow, true)
+	} else {
+		var err error
+		if lastModified, err = v.checkLastModified(lastModified, report); err != nil {
+			return kafka.FTMessage{}, "", err
+		}
 	}
+	report.record("publishReference", "header:X-Request-Id", ruleHeader, false)
 
 	var videoContent map[string]interface{}
 	if err := json.Unmarshal([]byte(m.Body), &videoContent); err != nil {
-		return kafka.FTMessage{}, "", fmt.Errorf("error: %v - Video JSON couldn't be unmarshalled. Skipping invalid JSON: %v", err.Error(), m.Body)
+		return kafka.FTMessage{}, "", &mappingError{errorClassInvalidJSON, fmt.Errorf("error: %v - Video JSON couldn't be unmarshalled. Skipping invalid JSON: %v", err.Error(), m.Body)}
 	}
 
 	isPublishEvent := isPublishEvent(videoContent)
@@ -61,7 +227,7 @@ func (v VideoMapper) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, e
 	if !isPublishEvent {
 		uuid, err := get("uuid", videoContent)
 		if err != nil {
-			return kafka.FTMessage{}, "", fmt.Errorf("error: %v - Could not extract UUID from video message. Skipping invalid JSON: %v", err.Error(), m.Body)
+			return kafka.FTMessage{}, "", &mappingError{errorClassMissingUUID, fmt.Errorf("error: %v - Could not extract UUID from video message. Skipping invalid JSON: %v", err.Error(), m.Body)}
 		}
 
 		contentURI := utils.GetPrefixedURL(videoContentURIBase, uuid)
@@ -70,6 +236,8 @@ func (v VideoMapper) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, e
 			ID:      uuid,
 			Deleted: true,
 		}
+		report.record("uuid", "$.uuid", ruleCopy, false)
+		report.record("deleted", "$.deleted", ruleCopy, false)
 
 		deleteVideoMsg, err := v.buildAndMarshalPublicationEvent(videoModel, contentURI, lastModified, tid)
 		return deleteVideoMsg, uuid, err
@@ -77,16 +245,31 @@ func (v VideoMapper) TransformMsg(m kafka.FTMessage) (kafka.FTMessage, string, e
 
 	uuid, err := get("id", videoContent)
 	if err != nil {
-		return kafka.FTMessage{}, "", fmt.Errorf("error: %v - Could not extract UUID from video message. Skipping invalid JSON: %v", err.Error(), m.Body)
+		return kafka.FTMessage{}, "", &mappingError{errorClassMissingUUID, fmt.Errorf("error: %v - Could not extract UUID from video message. Skipping invalid JSON: %v", err.Error(), m.Body)}
 	}
 
 	contentURI := utils.GetPrefixedURL(videoContentURIBase, uuid)
-	videoModel := v.getVideoModel(videoContent, uuid, tid, lastModified)
+	videoModel := v.getVideoModel(videoContent, uuid, lastModified, report)
+	if err := v.checkDates(videoModel, report); err != nil {
+		return kafka.FTMessage{}, uuid, err
+	}
+	report.blocked = v.applyPolicy(videoContent, videoModel, report)
+	if err := ctx.Err(); err != nil {
+		return kafka.FTMessage{}, uuid, &mappingError{errorClassCancelled, err}
+	}
 	message, err := v.buildAndMarshalPublicationEvent(videoModel, contentURI, lastModified, tid)
-	return message, uuid, err
+	if err != nil {
+		return kafka.FTMessage{}, uuid, err
+	}
+	// the blocked message is still returned, for ExplainMsg to explain it
+	if len(report.blocked) > 0 {
+		return message, uuid, &mappingError{errorClassBlocked, fmt.Errorf("video %v is blocked from publication: %v", uuid, strings.Join(report.blocked, "; "))}
+	}
+	return message, uuid, nil
 }
 
-func (v VideoMapper) getVideoModel(videoContent map[string]interface{}, uuid string, tid string, lastModified string) *videoPayload {
+func (v VideoMapper) getVideoModel(videoContent map[string]interface{}, uuid string, lastModified string, report *mappingReport) *videoPayload {
+	tid := report.tid
 	title, _ := get("title", videoContent)
 	standfirst, _ := get("standfirst", videoContent)
 	description, _ := get("description", videoContent)
@@ -100,28 +283,49 @@ func (v VideoMapper) getVideoModel(videoContent map[string]interface{}, uuid str
 	altStandfirsts, _ := getMap("alternativeStandfirsts", videoContent)
 	promotionalStandfirst, _ := get("promotionalStandfirst", altStandfirsts)
 
+	dataSources, posters, dataSourcesErr := getDataSources(videoContent["encoding"])
 	mainImage, err := getMainImage(videoContent)
+	mainImageFallback := ""
 	if err != nil {
-		v.log.Warnf("%v - Extract main image: %v", tid, err)
+		// mainImage only takes image UUIDs, so the poster frame goes to its own field
+		if poster, ok := bestPoster(posters); ok {
+			mainImageFallback = poster
+			v.warn(report, "mainImage", newFieldError(warningDefaulted, "%v", err), "Extract main image: %v. Using the poster frame %v as mainImageFallback", err, poster)
+		} else {
+			v.warn(report, "mainImage", err, "Extract main image: %v", err)
+		}
 	}
 
 	storyPackageUuid, err := getStoryPackageUUID(videoContent, uuid)
 	if err != nil {
-		v.log.Warnf("%v - Extract story package: %v", tid, err)
+		v.warn(report, "storyPackage", err, "Extract story package: %v", err)
 	}
 
 	transcriptionMap, transcript, err := getTranscript(videoContent, uuid)
+	cues := v.getCaptionCues(transcriptionMap, report)
+	transcriptAutoGenerated := false
+	if transcript == "" {
+		transcript = cuesTranscript(cues, v.getParagraphGap())
+		transcriptAutoGenerated = transcript != ""
+	}
+	if err != nil && !(transcriptAutoGenerated && warningCode(err) == warningMissing) {
+		v.warn(report, "transcript", err, "%v", err)
+	}
+
+	segments, segmentsFromCues, err := getTranscriptSegments(transcriptionMap, cues)
 	if err != nil {
-		v.log.Warnf("%v - %v", tid, err)
+		v.warn(report, "transcriptSegments", err, "%v", err)
 	}
 
-	captionsList := getCaptions(transcriptionMap)
-	dataSources, err := getDataSources(videoContent["encoding"])
+	captionsList, err := getCaptions(transcriptionMap)
 	if err != nil {
-		v.log.Warnf("%v - %v", tid, err)
+		v.warn(report, "captions", err, "%v", err)
+	}
+	if dataSourcesErr != nil {
+		v.warn(report, "dataSource", dataSourcesErr, "%v", dataSourcesErr)
 	}
 
-	canBeSyndicated := v.getCanBeSyndicated(videoContent, tid)
+	canBeSyndicated := v.getCanBeSyndicated(videoContent, report)
 
 	i := identifier{
 		Authority:       videoAuthority,
@@ -137,29 +341,69 @@ func (v VideoMapper) getVideoModel(videoContent map[string]interface{}, uuid str
 	webURL := fmt.Sprintf(webUrlTemplate, uuid)
 	canonicalWebURL := fmt.Sprintf(canonicalWebUrlTemplate, uuid)
 
-	return &videoPayload{
-		ID:                 uuid,
-		Title:              title,
-		Standfirst:         standfirst,
-		Description:        description,
-		Byline:             byline,
-		Identifiers:        []identifier{i},
-		Brands:             []brand{b},
-		FirstPublishedDate: firstPublishDate,
-		PublishedDate:      publishedDate,
-		MainImage:          mainImage,
-		StoryPackage:       storyPackageUuid,
-		Transcript:         transcript,
-		Captions:           captionsList,
-		DataSources:        dataSources,
-		CanBeDistributed:   canBeDistributedYes,
-		Type:               videoType,
-		LastModified:       lastModified,
-		PublishReference:   tid,
-		CanBeSyndicated:    canBeSyndicated,
-		AccessLevel:        accessLevel,
-		WebURL:             webURL,
-		CanonicalWebURL:    canonicalWebURL,
+	report.record("uuid", "$.id", ruleCopy, false)
+	report.record("title", "$.title", ruleCopy, false)
+	report.record("standfirst", "$.standfirst", ruleCopy, false)
+	report.record("description", "$.description", ruleCopy, false)
+	report.record("byline", "$.byline", ruleCopy, false)
+	report.record("identifiers", "$.id", ruleIdentifier, false)
+	report.record("brands", "", ruleConstant, false)
+	report.record("firstPublishedDate", "$.firstPublishedAt", ruleDate, false)
+	report.record("publishedDate", "$.publishedAt", ruleDate, false)
+	report.record("mainImage", "$.image", ruleUUID, false)
+	report.record("mainImageFallback", "$.encoding.outputs", rulePosterFrame, true)
+	report.record("storyPackage", "$.related", ruleStoryPackage, false)
+	if transcriptAutoGenerated {
+		report.record("transcript", "$.transcription.captions", ruleWebVTT, false)
+		report.record("transcriptAutoGenerated", "$.transcription.captions", ruleWebVTT, false)
+	} else {
+		report.record("transcript", "$.transcription.transcript", ruleBodyXML, false)
+	}
+	if segmentsFromCues {
+		report.record("transcriptSegments", "$.transcription.captions", ruleWebVTT, false)
+	} else {
+		report.record("transcriptSegments", "$.transcription.segments", ruleSegments, false)
+	}
+	report.record("captions", "$.transcription.captions", ruleCaptions, false)
+	report.record("dataSource", "$.encoding.outputs", ruleEncodingOutputs, false)
+	report.record("posterImages", "$.encoding.outputs", ruleEncodingOutputs, false)
+	report.record("canBeDistributed", "", ruleConstant, false)
+	report.record("type", "$.encoding.outputs", ruleContentType, false)
+	report.record("audio", "$.encoding.outputs", ruleContentType, false)
+	report.record("duration", "$.encoding.outputs", rulePrimaryRendition, false)
+	report.record("isoDuration", "$.encoding.outputs", rulePrimaryRendition, false)
+	report.record("accessLevel", "", ruleConstant, true)
+	report.record("webUrl", "$.id", ruleURLTemplate, false)
+	report.record("canonicalWebUrl", "$.id", ruleURLTemplate, false)
+	report.record("alternativeTitles.promotionalTitle", "$.alternativeTitles.promotionalTitle", ruleCopy, false)
+	report.record("alternativeStandfirsts.promotionalStandfirst", "$.alternativeStandfirsts.promotionalStandfirst", ruleCopy, false)
+
+	payload := &videoPayload{
+		ID:                      uuid,
+		Title:                   title,
+		Standfirst:              standfirst,
+		Description:             description,
+		Byline:                  byline,
+		Identifiers:             []identifier{i},
+		Brands:                  []brand{b},
+		FirstPublishedDate:      firstPublishDate,
+		PublishedDate:           publishedDate,
+		MainImage:               mainImage,
+		MainImageFallback:       mainImageFallback,
+		StoryPackage:            storyPackageUuid,
+		Transcript:              transcript,
+		TranscriptAutoGenerated: transcriptAutoGenerated,
+		TranscriptSegments:      segments,
+		Captions:                captionsList,
+		DataSources:             dataSources,
+		PosterImages:            posters,
+		CanBeDistributed:        canBeDistributedYes,
+		LastModified:            lastModified,
+		PublishReference:        tid,
+		CanBeSyndicated:         canBeSyndicated,
+		AccessLevel:             accessLevel,
+		WebURL:                  webURL,
+		CanonicalWebURL:         canonicalWebURL,
 		AlternativeTitles: &alternativeTitles{
 			PromotionalTitle: promotionalTitle,
 		},
@@ -167,12 +411,32 @@ func (v VideoMapper) getVideoModel(videoContent map[string]interface{}, uuid str
 			PromotionalStandfirst: promotionalStandfirst,
 		},
 	}
+	if err := v.setRenditionFields(videoContent, payload); err != nil {
+		v.warn(report, "duration", err, "%v", err)
+	}
+	return payload
 }
-func (v VideoMapper) getCanBeSyndicated(videoContent map[string]interface{}, tid string) string {
+
+// setRenditionFields derives the type, the audio rendition and the duration of the content from its data sources.
+func (v VideoMapper) setRenditionFields(videoContent map[string]interface{}, payload *videoPayload) error {
+	duration, err := getDuration(payload.DataSources, v.getDurationTolerance())
+	payload.Duration = duration
+	payload.ISODuration = ""
+	if duration != nil {
+		payload.ISODuration = isoDuration(*duration)
+	}
+	payload.Type, payload.Audio = v.contentType(videoContent, payload.DataSources)
+	return err
+}
+
+func (v VideoMapper) getCanBeSyndicated(videoContent map[string]interface{}, report *mappingReport) string {
 	canBeSyndicated, err := getBool("canBeSyndicated", videoContent)
 	if err != nil {
-		v.log.Warnf("%v - %v. Defaulting value to true", tid, err)
+		v.warn(report, "canBeSyndicated", newFieldError(warningDefaulted, "%v", err), "%v. Defaulting value to true", err)
 		canBeSyndicated = true
+		report.record("canBeSyndicated", "$.canBeSyndicated", ruleYesNo, true)
+	} else {
+		report.record("canBeSyndicated", "$.canBeSyndicated", ruleYesNo, false)
 	}
 	switch canBeSyndicated {
 	case false:
@@ -182,6 +446,21 @@ func (v VideoMapper) getCanBeSyndicated(videoContent map[string]interface{}, tid
 	}
 }
 
+// warn logs a problem found in the native video and adds it to the report of the message.
+func (v VideoMapper) warn(report *mappingReport, field string, err error, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	v.log.Warnf("%v - %v", report.tid, message)
+	report.warnings = append(report.warnings, MappingWarning{
+		Field:   field,
+		Code:    warningCode(err),
+		Message: message,
+		Details: warningDetails(err),
+	})
+	if v.metrics != nil {
+		v.metrics.MappingWarning(field)
+	}
+}
+
 func getMainImage(videoContent map[string]interface{}) (string, error) {
 	image, err := get("image", videoContent)
 	if err != nil {
@@ -189,7 +468,7 @@ func getMainImage(videoContent map[string]interface{}) (string, error) {
 	}
 
 	if _, err = uuid.Parse(image); err != nil {
-		return "", fmt.Errorf("invalid image format: %s", image)
+		return "", newFieldError(warningInvalidFormat, "invalid image format: %s", image)
 	}
 
 	return image, nil
@@ -198,7 +477,7 @@ func getMainImage(videoContent map[string]interface{}) (string, error) {
 func getStoryPackageUUID(videoContent map[string]interface{}, videoUUID string) (string, error) {
 	_, ok := videoContent["related"]
 	if !ok {
-		return "", fmt.Errorf("Related content is null and will be skipped for uuid: %v", videoUUID)
+		return "", newFieldError(warningMissing, "Related content is null and will be skipped for uuid: %v", videoUUID)
 	}
 
 	vUUID, err := uuidUtils.NewUUIDFromString(videoUUID)
@@ -218,12 +497,12 @@ func getStoryPackageUUID(videoContent map[string]interface{}, videoUUID string)
 func getTranscript(videoContent map[string]interface{}, uuid string) (map[string]interface{}, string, error) {
 	transcription, ok := videoContent["transcription"]
 	if !ok {
-		return nil, "", fmt.Errorf("Transcription is null and will be skipped for uuid: %v", uuid)
+		return nil, "", newFieldError(warningMissing, "Transcription is null and will be skipped for uuid: %v", uuid)
 	}
 
 	transcriptionMap, ok := transcription.(map[string]interface{})
 	if !ok {
-		return nil, "", fmt.Errorf("Transcription is null and will be skipped for uuid: %v", uuid)
+		return nil, "", newFieldError(warningWrongType, "Transcription is null and will be skipped for uuid: %v", uuid)
 	}
 
 	transcript, err := get("transcript", transcriptionMap)
@@ -231,76 +510,65 @@ func getTranscript(videoContent map[string]interface{}, uuid string) (map[string
 		return transcriptionMap, "", err
 	}
 
-	valid := utils.IsValidXHTML(transcript)
-	if !valid {
-		return transcriptionMap, "", fmt.Errorf("Transcription has invalid HTML body and will be skipped for uuid: %v", uuid)
+	xhtmlErrs := utils.ValidateXHTML(transcript,
+		utils.AllXHTMLErrors(),
+		utils.WithAllowedElements(utils.BodyXMLElements),
+		utils.WithNestingRules(utils.BodyXMLNesting))
+	body, changes := utils.SanitiseTranscript(transcript)
+	if bodyErrs := utils.ValidateXHTML(body); len(bodyErrs) > 0 {
+		return transcriptionMap, "", newXHTMLFieldError(warningInvalidXHTML, bodyErrs, "Transcription has invalid HTML body and will be skipped for uuid: %v", uuid)
+	}
+	if len(changes) > 0 {
+		return transcriptionMap, body, newXHTMLFieldError(warningSanitised, xhtmlErrs, "Transcription was sanitised for uuid: %v: %v", uuid, strings.Join(changes, "; "))
+	}
+	if len(xhtmlErrs) > 0 {
+		return transcriptionMap, body, newXHTMLFieldError(warningInvalidXHTML, xhtmlErrs, "Transcription has invalid markup and is kept as is for uuid: %v", uuid)
 	}
 
-	return transcriptionMap, transcript, nil
+	return transcriptionMap, body, nil
 }
 
-func getCaptions(transcriptionMap map[string]interface{}) []caption {
-	cList := []caption{}
-	if transcriptionMap == nil {
-		return cList
+// getCaptionCues parses the inline WebVTT captions, if any.
+func (v VideoMapper) getCaptionCues(transcriptionMap map[string]interface{}, report *mappingReport) []utils.Cue {
+	content, ok := getInlineWebVTT(transcriptionMap)
+	if !ok {
+		return nil
 	}
 
-	captions, _ := transcriptionMap["captions"].([]interface{})
-	for _, elem := range captions {
-		captionMap := elem.(map[string]interface{})
-		mediaType, _ := get("mediaType", captionMap)
-		url, _ := get("url", captionMap)
-
-		c := caption{
-			Url:       url,
-			MediaType: mediaType,
-		}
-		cList = append(cList, c)
+	cues, err := utils.ParseWebVTT(content)
+	if err != nil {
+		v.warn(report, "transcript", newFieldError(warningMalformed, "%v", err), "Couldn't generate a transcript from the WebVTT captions: %v", err)
+		return nil
 	}
-
-	return cList
+	return cues
 }
 
-func getDataSources(encoding interface{}) ([]dataSource, error) {
-	encodingMap, ok := encoding.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("Encodings field of video JSON is null, dataSource will be empty.")
+func (v VideoMapper) now() time.Time {
+	if v.clock == nil {
+		return time.Now()
 	}
+	return v.clock()
+}
 
-	outputs, ok := encodingMap["outputs"]
-	if !ok {
-		return nil, fmt.Errorf("Outputs field of video JSON is null, dataSource will be empty.")
+func (v VideoMapper) newMessageID() string {
+	if v.messageID == nil {
+		return uuid.New().String()
 	}
+	return v.messageID()
+}
 
-	outputsArray := outputs.([]interface{})
-	dataSourcesList := []dataSource{}
-	for _, elem := range outputsArray {
-		elemMap, okMap := elem.(map[string]interface{})
-		if !okMap {
-			return nil, fmt.Errorf("Cannot extract output field of video JSON, dataSource will be empty.")
-		}
-		binaryUrl, _ := get("url", elemMap)
-		pWidth, _ := getNumber("width", elemMap)
-		pHeight, _ := getNumber("height", elemMap)
-		mediaType, _ := get("mediaType", elemMap)
-		videoCodec, _ := get("videoCodec", elemMap)
-		audioCodec, _ := get("audioCodec", elemMap)
-		duration, _ := getNumber("duration", elemMap)
-
-		d := dataSource{
-			BinaryUrl:   binaryUrl,
-			PixelWidth:  pWidth,
-			PixelHeight: pHeight,
-			MediaType:   mediaType,
-			Duration:    duration,
-			VideoCodec:  videoCodec,
-			AudioCodec:  audioCodec,
-		}
-
-		dataSourcesList = append(dataSourcesList, d)
+func (v VideoMapper) getDurationTolerance() time.Duration {
+	if v.durationTolerance <= 0 {
+		return defaultDurationTolerance
 	}
+	return v.durationTolerance
+}
 
-	return dataSourcesList, nil
+func (v VideoMapper) getParagraphGap() time.Duration {
+	if v.paragraphGap <= 0 {
+		return defaultParagraphGap
+	}
+	return v.paragraphGap
 }
 
 func getAccessLevel() string {
@@ -317,13 +585,13 @@ func (v VideoMapper) buildAndMarshalPublicationEvent(p *videoPayload, contentURI
 	marshalledEvent, err := utils.UnsafeJSONMarshal(e)
 	if err != nil {
 		v.log.Warnf("%v - Couldn't marshall event %v, skipping message.", pubRef, e)
-		return kafka.FTMessage{}, err
+		return kafka.FTMessage{}, &mappingError{errorClassMarshal, err}
 	}
 
 	headers := map[string]string{
 		"X-Request-Id":      pubRef,
 		"Message-Timestamp": lastModified,
-		"Message-Id":        uuid.New().String(),
+		"Message-Id":        v.newMessageID(),
 		"Message-Type":      "cms-content-published",
 		"Content-Type":      "application/json",
 		"Origin-System-Id":  systemOrigin,
@@ -344,12 +612,12 @@ func isPublishEvent(video map[string]interface{}) bool {
 func get(key string, videoContent map[string]interface{}) (val string, _ error) {
 	valueI, ok := videoContent[key]
 	if !ok {
-		return "", fmt.Errorf("[%s] field of native video JSON is null", key)
+		return "", newFieldError(warningMissing, "[%s] field of native video JSON is null", key)
 	}
 
 	val, ok = valueI.(string)
 	if !ok {
-		return "", fmt.Errorf("[%s] field of native video JSON is not a string", key)
+		return "", newFieldError(warningWrongType, "[%s] field of native video JSON is not a string", key)
 	}
 	return val, nil
 }
@@ -358,12 +626,12 @@ func getMap(key string, content map[string]interface{}) (map[string]interface{},
 
 	val, ok := content[key]
 	if !ok {
-		return nil, fmt.Errorf("[%s] field of native video JSON is null", key)
+		return nil, newFieldError(warningMissing, "[%s] field of native video JSON is null", key)
 	}
 
 	retval, ok := val.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("[%s] field of native video JSON is not a string", key)
+		return nil, newFieldError(warningWrongType, "[%s] field of native video JSON is not a string", key)
 	}
 	return retval, nil
 }
@@ -371,12 +639,12 @@ func getMap(key string, content map[string]interface{}) (map[string]interface{},
 func getNumber(key string, inputMap map[string]interface{}) (*float64, error) {
 	valueI, ok := inputMap[key]
 	if !ok {
-		return nil, fmt.Errorf("[%s] field of native video JSON is null", key)
+		return nil, newFieldError(warningMissing, "[%s] field of native video JSON is null", key)
 	}
 
 	val, isOk := valueI.(float64)
 	if !isOk {
-		return nil, fmt.Errorf("[%s] field of native video JSON is not a number", key)
+		return nil, newFieldError(warningWrongType, "[%s] field of native video JSON is not a number", key)
 	}
 
 	return &val, nil
@@ -385,12 +653,12 @@ func getNumber(key string, inputMap map[string]interface{}) (*float64, error) {
 func getBool(key string, inputMap map[string]interface{}) (bool, error) {
 	valueI, ok := inputMap[key]
 	if !ok {
-		return false, fmt.Errorf("[%s] field of native video JSON is null", key)
+		return false, newFieldError(warningMissing, "[%s] field of native video JSON is null", key)
 	}
 
 	val, isOk := valueI.(bool)
 	if !isOk {
-		return false, fmt.Errorf("[%s] field of native video JSON is not a bool", key)
+		return false, newFieldError(warningWrongType, "[%s] field of native video JSON is not a bool", key)
 	}
 
 	return val, nil
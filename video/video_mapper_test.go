@@ -1,12 +1,21 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/go-logger/v2"
 	"github.com/Financial-Times/kafka-client-go/v4"
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -24,7 +33,7 @@ func TestTransformMsg_TidHeaderMissing(t *testing.T) {
 		Body: `{}`,
 	}
 
-	_, _, err := mapper.TransformMsg(message)
+	_, _, _, err := mapper.TransformMsg(message)
 	assert.EqualError(t, err, "header X-Request-Id not found in kafka message headers. Skipping message", "Expected error when X-Request-Id is missing")
 }
 
@@ -38,7 +47,7 @@ func TestTransformMsg_MessageTimestampHeaderMissing(t *testing.T) {
 		}`,
 	}
 
-	msg, _, err := mapper.TransformMsg(message)
+	msg, _, _, err := mapper.TransformMsg(message)
 	assert.NoError(t, err, "Error not expected when Message-Timestamp header is missing")
 	assert.NotEmpty(t, msg.Body, "Message body should not be empty")
 	assert.Contains(t, msg.Body, "\"lastModified\":", "LastModified field should be generated if header value is missing")
@@ -57,7 +66,7 @@ func TestTransformMsg_InvalidJson(t *testing.T) {
 					"id": "bad50c54-76d9-30e9-8734-b999c708aa4c"}`,
 	}
 
-	_, _, err := mapper.TransformMsg(message)
+	_, _, _, err := mapper.TransformMsg(message)
 	assert.Error(t, err, "Expected error when invalid JSON for video content")
 	assert.Contains(t, err.Error(), "Video JSON couldn't be unmarshalled. Skipping invalid JSON:", "Expected error message when invalid JSON for video content")
 }
@@ -70,7 +79,7 @@ func TestTransformMsg_UuidMissing(t *testing.T) {
 		Body: `{}`,
 	}
 
-	_, _, err := mapper.TransformMsg(message)
+	_, _, _, err := mapper.TransformMsg(message)
 	assert.Error(t, err, "Expected error when video UUID is missing")
 	assert.Contains(t, err.Error(), "Could not extract UUID from video message. Skipping invalid JSON:", "Expected error when video UUID is missing")
 }
@@ -89,7 +98,7 @@ func TestTransformMsg_UnpublishEvent(t *testing.T) {
 					"uuid": "bad50c54-76d9-30e9-8734-b999c708aa4c"}`,
 	}
 
-	resultMsg, uuid, err := mapper.TransformMsg(message)
+	resultMsg, uuid, _, err := mapper.TransformMsg(message)
 	assert.NoError(t, err, "Error not expected for unpublish event")
 	assert.Equal(t, "bad50c54-76d9-30e9-8734-b999c708aa4c", uuid, "UUID not extracted correctly from unpublish event")
 	assert.Equal(t, "{\"contentUri\":\"http://next-video-mapper.svc.ft.com/video/model/bad50c54-76d9-30e9-8734-b999c708aa4c\",\"payload\":{\"uuid\":\"bad50c54-76d9-30e9-8734-b999c708aa4c\",\"deleted\":true},\"lastModified\":\"2017-04-13T10:27:32.353Z\"}", resultMsg.Body)
@@ -113,7 +122,7 @@ func TestTransformMsg_Success(t *testing.T) {
 		Body: videoInput,
 	}
 
-	resultMsg, _, err := mapper.TransformMsg(message)
+	resultMsg, _, _, err := mapper.TransformMsg(message)
 	assert.NoError(t, err, "Error not expected for publish event")
 
 	videoOutputStruct, resultMsgStruct, err := MapStringToPublicationEvent(videoOutput, resultMsg.Body)
@@ -143,11 +152,206 @@ func TestTransformMsg_WithStoryPackage(t *testing.T) {
 				}`,
 	}
 
-	resultMsg, _, err := mapper.TransformMsg(message)
+	resultMsg, _, _, err := mapper.TransformMsg(message)
 	assert.NoError(t, err, "Error not expected for unpublish event")
 	assert.Contains(t, resultMsg.Body, "\"storyPackage\":\"a40808ac-1417-4c48-2945-63c109d95533\"")
 }
 
+func TestTransformMsg_WarningMetrics(t *testing.T) {
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{
+					"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+					"image": "not-a-uuid",
+					"canBeSyndicated": false,
+					"transcription": {"transcript": "<p>unclosed"}
+				}`,
+	}
+
+	metrics := &mockMetrics{warnings: map[string]int{}}
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithMapperMetrics(metrics))
+	_, _, _, err := m.TransformMsg(message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"mainImage": 1, "storyPackage": 1, "transcript": 1, "dataSource": 1}, metrics.warnings)
+}
+
+func TestTransformMsg_Warnings(t *testing.T) {
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{
+					"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+					"image": "not-a-uuid",
+					"transcription": {"transcript": "<p>unclosed"},
+					"encoding": {"outputs": ["not an output"]}
+				}`,
+	}
+
+	_, _, warnings, err := mapper.TransformMsg(message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []MappingWarning{
+		{Field: "mainImage", Code: warningInvalidFormat, Message: "Extract main image: invalid image format: not-a-uuid"},
+		{Field: "storyPackage", Code: warningMissing, Message: "Extract story package: Related content is null and will be skipped for uuid: a40808ac-1417-4c48-9781-1dd2d8c8c6dc"},
+		{Field: "transcript", Code: warningSanitised, Message: "Transcription was sanitised for uuid: a40808ac-1417-4c48-9781-1dd2d8c8c6dc: closed unclosed <p>",
+			Details: []utils.XHTMLError{{Line: 1, Column: 12, Message: "unexpected EOF"}}},
+		{Field: "dataSource", Code: warningMalformed, Message: "Skipped encoding outputs: output 0 is not an object"},
+		{Field: "canBeSyndicated", Code: warningDefaulted, Message: "[canBeSyndicated] field of native video JSON is null. Defaulting value to true"},
+	}, warnings)
+}
+
+func TestTransformMsg_NoWarnings(t *testing.T) {
+	videoInput, err := readContent("video-input.json")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Input data for test cannot be loaded from external file")
+	}
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: strings.Replace(videoInput, `"canBeSyndicated": true,`, `"canBeSyndicated": true, "related": [],`, 1),
+	}
+
+	_, _, warnings, err := mapper.TransformMsg(message)
+
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestTransformMsg_PosterFrameMainImageFallback(t *testing.T) {
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{
+					"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc",
+					"encoding": {"outputs": [{"mediaType": "image/jpeg", "width": 1280, "height": 720, "url": "http://ftvideo.example.com/poster.jpg"}]}
+				}`,
+	}
+
+	msg, _, warnings, err := mapper.TransformMsg(message)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, msg.Body, `"mainImage"`, "The main image should only be an image UUID")
+	assert.Contains(t, msg.Body, `"mainImageFallback":"http://ftvideo.example.com/poster.jpg"`)
+	assert.Contains(t, warnings, MappingWarning{Field: "mainImage", Code: warningDefaulted, Message: "Extract main image: [image] field of native video JSON is null. Using the poster frame http://ftvideo.example.com/poster.jpg as mainImageFallback"})
+
+	explanation, err := mapper.ExplainMsg(context.Background(), message)
+
+	require.NoError(t, err)
+	assert.Contains(t, explanation.Fields, FieldProvenance{Field: "mainImageFallback", Source: "$.encoding.outputs", Rule: rulePosterFrame, Defaulted: true})
+}
+
+func TestTransformMsg_Deterministic(t *testing.T) {
+	videoInput, err := readContent("video-input.json")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Input data for test cannot be loaded from external file")
+	}
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id": xRequestId,
+		},
+		Body: videoInput,
+	}
+	clock := func() time.Time { return time.Date(2017, 4, 13, 11, 27, 32, 353000000, time.FixedZone("BST", 3600)) }
+	ids := 0
+	messageID := func() string {
+		ids++
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", ids)
+	}
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Debug"), WithClock(clock), WithMessageIDGenerator(messageID))
+
+	first, _, _, err := m.TransformMsg(message)
+	assert.NoError(t, err)
+	ids = 0
+	second, _, _, err := m.TransformMsg(message)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", first.Headers["Message-Id"])
+	assert.Equal(t, "2017-04-13T10:27:32.353Z", first.Headers["Message-Timestamp"])
+}
+
+func TestTransformMsgContext_Cancelled(t *testing.T) {
+	var message = kafka.FTMessage{
+		Headers: map[string]string{
+			"X-Request-Id":      xRequestId,
+			"Message-Timestamp": messageTimestamp,
+		},
+		Body: `{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err := mapper.TransformMsgContext(ctx, message)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, errorClassCancelled, errorClass(err))
+}
+
+func TestMappingError_Rejected(t *testing.T) {
+	_, _, _, err := mapper.TransformMsg(kafka.FTMessage{Headers: map[string]string{"X-Request-Id": xRequestId}, Body: `{`})
+	var invalid *mappingError
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.True(t, invalid.Rejected(), "Invalid messages should be rejected")
+	}
+	assert.False(t, (&mappingError{errorClassCancelled, context.Canceled}).Rejected(), "Cancelled messages are not at fault")
+	assert.False(t, (&mappingError{errorClassMarshal, errors.New("unsupported value")}).Rejected())
+}
+
+// FuzzTransformMsg checks that no native video or Message-Timestamp header makes the mapper panic,
+// and that the mapped messages are valid JSON with a well-formed transcript.
+func FuzzTransformMsg(f *testing.F) {
+	fixtures, err := filepath.Glob(filepath.Join(goldenDir, "*.input.json"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, fixture := range append(fixtures, "test-resources/video-input.json") {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data), messageTimestamp)
+	}
+	f.Add(`{"deleted": true, "uuid": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc"}`, "")
+	f.Add(`{"id": "a40808ac-1417-4c48-9781-1dd2d8c8c6dc", "encoding": {"outputs": [null, [], 1e400]}}`, "13 April 2017")
+
+	m := NewVideoMapper(logger.NewUPPLogger("video-mapper", "Panic"), WithPolicy(Policy{Rules: []PolicyRule{
+		{Rule: RuleMP4Required, Severity: SeverityStrip},
+		{Rule: RuleCaptionsRequired, Severity: SeverityWarn, MinDurationSeconds: 30},
+		{Rule: RuleMainImageValid, Severity: SeverityWarn},
+	}}))
+	f.Fuzz(func(t *testing.T, body string, timestamp string) {
+		message := kafka.FTMessage{
+			Headers: map[string]string{
+				"X-Request-Id":      xRequestId,
+				"Message-Timestamp": timestamp,
+			},
+			Body: body,
+		}
+		mapped, _, _, err := m.TransformMsg(message)
+		if err != nil {
+			return
+		}
+
+		var event publicationEvent
+		if err := json.Unmarshal([]byte(mapped.Body), &event); err != nil {
+			t.Fatalf("mapped message is not valid JSON: %v", err)
+		}
+		if transcript := event.Payload.Transcript; transcript != "" && !utils.IsValidXHTML(transcript) {
+			t.Fatalf("mapped transcript is not well-formed: %q", transcript)
+		}
+	})
+}
+
 func MapStringToPublicationEvent(videoOutput, retMsgBody string) (videoOutputStruct, resultMsgStruct *publicationEvent, err error) {
 	videoOutputStruct = &publicationEvent{}
 	resultMsgStruct = &publicationEvent{}
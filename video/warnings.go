@@ -0,0 +1,90 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Financial-Times/upp-next-video-mapper/utils"
+)
+
+const (
+	warningMissing       = "missing"
+	warningWrongType     = "wrong_type"
+	warningInvalidFormat = "invalid_format"
+	warningInvalidXHTML  = "invalid_xhtml"
+	warningMalformed     = "malformed"
+	warningDefaulted     = "defaulted"
+	warningSanitised     = "sanitised"
+	warningInconsistent  = "inconsistent"
+	warningInvalidDate   = "invalid_date"
+)
+
+// MappingWarning describes a problem in the native video that made the mapper drop or default an output field.
+// Details locate the markup problems of XHTML fields.
+type MappingWarning struct {
+	Field   string             `json:"field"`
+	Code    string             `json:"code"`
+	Message string             `json:"message"`
+	Details []utils.XHTMLError `json:"details,omitempty"`
+}
+
+// fieldError is returned by the extraction helpers, so the warning code survives until the warning is raised.
+type fieldError struct {
+	code    string
+	msg     string
+	details []utils.XHTMLError
+}
+
+func (e *fieldError) Error() string {
+	return e.msg
+}
+
+func newFieldError(code string, format string, args ...interface{}) error {
+	return &fieldError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func newXHTMLFieldError(code string, details []utils.XHTMLError, format string, args ...interface{}) error {
+	return &fieldError{code: code, msg: fmt.Sprintf(format, args...), details: details}
+}
+
+func warningDetails(err error) []utils.XHTMLError {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return fe.details
+	}
+	return nil
+}
+
+func warningCode(err error) string {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return fe.code
+	}
+	return warningInvalidFormat
+}
+
+// mappingReport collects what happened while mapping a single message.
+// The provenance of the fields is only kept when the mapping is explained.
+type mappingReport struct {
+	tid        string
+	warnings   []MappingWarning
+	explain    bool
+	provenance []FieldProvenance
+	blocked    []string
+}
+
+func newMappingReport(tid string, explain bool) *mappingReport {
+	return &mappingReport{tid: tid, explain: explain}
+}
+
+func (r *mappingReport) record(field string, source string, rule string, defaulted bool) {
+	if !r.explain {
+		return
+	}
+	r.provenance = append(r.provenance, FieldProvenance{
+		Field:     field,
+		Source:    source,
+		Rule:      rule,
+		Defaulted: defaulted,
+	})
+}